@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for common Bot API failures. They are matched by APIError with errors.Is.
+var (
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrChatNotFound   = errors.New("chat not found")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// APIError - error returned by the Bot API.
+// StatusCode - HTTP status of the response.
+// Ok - ok flag of the response envelope.
+// Code - error code from the response envelope, if any.
+// Description - error description from the response envelope, or the raw response body if it is not JSON.
+// Endpoint - path of the method relative to the base URL, e.g. "messages/sendText/".
+// RetryAfter - value of the Retry-After header, zero if it is absent.
+type APIError struct {
+	StatusCode  int
+	Ok          bool
+	Code        string
+	Description string
+	Endpoint    string
+	RetryAfter  time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %d", e.Endpoint, e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Is - reports whether the error matches one of the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrChatNotFound:
+		return e.mentions("chat_not_found", "chat not found")
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest || e.mentions("invalid_request")
+	}
+	return false
+}
+
+func (e *APIError) mentions(words ...string) bool {
+	text := strings.ToLower(e.Code + " " + e.Description)
+	for _, w := range words {
+		if strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type envelope struct {
+	Ok          *bool           `json:"ok"`
+	Code        json.RawMessage `json:"code,omitempty"`
+	Description json.RawMessage `json:"description,omitempty"`
+}
+
+// CheckResponse - checks status code and ok flag of the response.
+// It returns *APIError if the request failed or the ok flag is missing, and an error if a successful response is not valid JSON.
+func CheckResponse(endpoint string, resp *Response) error {
+	return checkResponse(endpoint, resp, false)
+}
+
+// checkResponse - same as CheckResponse, if okOptional a successful response without the ok flag is considered ok.
+func checkResponse(endpoint string, resp *Response, okOptional bool) error {
+	body := resp.Body
+	env := envelope{}
+	jsonErr := json.Unmarshal(body, &env)
+	if resp.StatusCode == http.StatusOK && jsonErr == nil && (env.Ok == nil && okOptional || env.Ok != nil && *env.Ok) {
+		return nil
+	}
+	if resp.StatusCode == http.StatusOK && jsonErr != nil {
+		return fmt.Errorf("%s: decode response: %w", endpoint, jsonErr)
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpoint,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+	if jsonErr != nil {
+		apiErr.Description = strings.TrimSpace(string(body))
+		return apiErr
+	}
+	apiErr.Ok = env.Ok != nil && *env.Ok
+	apiErr.Code = rawString(env.Code)
+	apiErr.Description = rawString(env.Description)
+	if env.Ok == nil && apiErr.Description == "" {
+		apiErr.Description = "response without ok flag: " + strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+// rawString - JSON string as is, any other JSON value as its text.
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	s := ""
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
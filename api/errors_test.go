@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckResponseOkFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		okOptional bool
+		wantErr    bool
+	}{
+		{"ok", http.StatusOK, `{"ok":true,"message_id":1}`, false, false},
+		{"not ok", http.StatusOK, `{"ok":false,"description":"bad"}`, false, true},
+		{"empty object", http.StatusOK, `{}`, false, true},
+		{"null", http.StatusOK, `null`, false, true},
+		{"no ok flag, optional", http.StatusOK, `{"id":"bot"}`, true, false},
+		{"not ok, optional", http.StatusOK, `{"ok":false}`, true, true},
+		{"error status, optional", http.StatusForbidden, `{"id":"bot"}`, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &Response{StatusCode: tt.status, Header: http.Header{}, Body: []byte(tt.body)}
+			err := checkResponse("messages/sendText/", resp, tt.okOptional)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkResponse() = %v, want error %v", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if err != nil && !errors.As(err, &apiErr) {
+				t.Fatalf("checkResponse() = %T, want *APIError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// Request - request to a Bot API method.
+// Every call creates its own Request, so middlewares may change it, e.g. add headers.
+// Method - HTTP method.
+// Endpoint - path of the method relative to the base URL, e.g. "messages/sendText/".
+// Header - headers added to the default ones. A header with a single empty value removes the default header.
+// Body - request body. Content-Type is application/json unless Header sets another one.
+// LimitKey - key of the per-chat rate limit, see ChatKey.
+// Retry - the request may be repeated by the retry policy.
+// OkOptional - a successful response without the ok flag is accepted, e.g. of self/update/. Other methods require it.
+type Request struct {
+	Method     string
+	Endpoint   string
+	Header     http.Header
+	Body       []byte
+	LimitKey   string
+	Retry      bool
+	OkOptional bool
+}
+
+// Response - response of a Bot API method with the body already read.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Handler - executes a request. The last handler of the chain sends it over HTTP.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware - wraps Handler to add behaviour such as logging, metrics, tracing or fault injection.
+type Middleware func(next Handler) Handler
+
+// Executor - single request pipeline shared by all sub-clients.
+// Requests pass the retry policy, then the middlewares in the order they were given, then the rate limiter,
+// and finally are sent with the http.Client.
+// Executor is safe for concurrent use: its configuration is not changed after creation,
+// and every HTTP request gets its own copy of the headers.
+type Executor struct {
+	client  *http.Client
+	baseURL string
+	headers http.Header
+	handler Handler
+}
+
+// NewExecutor - creates Executor that sends requests to baseURL with headers added to every request.
+// headers are copied, so later changes of the map do not affect Executor. limiter may be nil.
+func NewExecutor(client *http.Client, baseURL string, headers http.Header, retry RetryPolicy, limiter *RateLimiter, middlewares ...Middleware) *Executor {
+	e := &Executor{
+		client:  client,
+		baseURL: baseURL,
+		headers: headers.Clone(),
+	}
+	h := e.send
+	if limiter != nil {
+		h = limiter.Middleware()(h)
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	e.handler = retry.Middleware()(h)
+	return e
+}
+
+// NewJSONRequest - Request with v encoded to JSON as body.
+func NewJSONRequest(method, endpoint string, v any) (*Request, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{
+		Method:   method,
+		Endpoint: endpoint,
+		Body:     data,
+	}, nil
+}
+
+// BaseURL - base URL of the Bot API.
+func (e *Executor) BaseURL() string {
+	return e.baseURL
+}
+
+// Do - executes req, checks the response and decodes its body to out. out may be nil.
+func (e *Executor) Do(ctx context.Context, req *Request, out any) error {
+	resp, err := e.handler(ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(req.Endpoint, resp, req.OkOptional); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Body, out)
+}
+
+// Download - executes req whose successful response is not a JSON envelope, e.g. file contents, and returns the body.
+func (e *Executor) Download(ctx context.Context, req *Request) ([]byte, error) {
+	resp, err := e.handler(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, CheckResponse(req.Endpoint, resp)
+	}
+	return resp.Body, nil
+}
+
+func (e *Executor) send(ctx context.Context, req *Request) (*Response, error) {
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, e.baseURL+req.Endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	header := e.headers.Clone()
+	if req.Body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.Header {
+		if len(v) == 1 && v[0] == "" {
+			header.Del(k)
+			continue
+		}
+		header[k] = slices.Clone(v)
+	}
+	httpReq.Header = header
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const sweepThreshold = 1024
+
+// Rate - limit of a token bucket: PerSecond requests per second on average with bursts up to Burst requests.
+// Zero PerSecond means no limit.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// LimiterStats - statistics of RateLimiter.
+// Requests - number of requests that passed the limiter.
+// Throttled - number of requests that had to wait or were not allowed.
+// Waited - total time spent waiting.
+type LimiterStats struct {
+	Requests  int64
+	Throttled int64
+	Waited    time.Duration
+}
+
+// RateLimiter - token-bucket limiter of outbound requests with a global limit and a limit per key (chat or login).
+// It is safe for concurrent use and is meant to be shared by all sub-clients.
+type RateLimiter struct {
+	global *bucket
+	perKey Rate
+
+	mu   sync.Mutex
+	keys map[string]*bucket
+
+	requests  atomic.Int64
+	throttled atomic.Int64
+	waited    atomic.Int64
+}
+
+func NewRateLimiter(global, perKey Rate) *RateLimiter {
+	return &RateLimiter{
+		global: newBucket(global),
+		perKey: perKey,
+		keys:   map[string]*bucket{},
+	}
+}
+
+// Wait - blocks until the request with key is allowed by both global and per-key limits.
+// Empty key is limited only by the global limit.
+// If ctx is done, or its deadline comes before the request is allowed, Wait returns the context error without taking a token.
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	reserved := make([]*bucket, 0, 2)
+	var delay time.Duration
+	for _, b := range []*bucket{l.global, l.bucket(key, now)} {
+		if b == nil {
+			continue
+		}
+		reserved = append(reserved, b)
+		delay = max(delay, b.reserve(now))
+	}
+	cancel := func() {
+		for _, b := range reserved {
+			b.cancel()
+		}
+	}
+
+	if delay > 0 {
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+			cancel()
+			return context.DeadlineExceeded
+		}
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			cancel()
+			return ctx.Err()
+		case <-t.C:
+		}
+		l.throttled.Add(1)
+		l.waited.Add(int64(delay))
+	}
+	l.requests.Add(1)
+	return nil
+}
+
+// Allow - takes a token for the request with key if it is available right now, without waiting.
+// It reports whether the request is allowed.
+func (l *RateLimiter) Allow(key string) bool {
+	now := time.Now()
+	var reserved []*bucket
+	for _, b := range []*bucket{l.global, l.bucket(key, now)} {
+		if b == nil {
+			continue
+		}
+		reserved = append(reserved, b)
+		if b.reserve(now) > 0 {
+			for _, r := range reserved {
+				r.cancel()
+			}
+			l.throttled.Add(1)
+			return false
+		}
+	}
+	l.requests.Add(1)
+	return true
+}
+
+// Stats - statistics collected since the limiter was created.
+func (l *RateLimiter) Stats() LimiterStats {
+	return LimiterStats{
+		Requests:  l.requests.Load(),
+		Throttled: l.throttled.Load(),
+		Waited:    time.Duration(l.waited.Load()),
+	}
+}
+
+// Middleware - makes every request wait for the limiter with Request.LimitKey.
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if err := l.Wait(ctx, req.LimitKey); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func (l *RateLimiter) bucket(key string, now time.Time) *bucket {
+	if key == "" || l.perKey.PerSecond <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.keys[key]
+	if !ok {
+		if len(l.keys) >= sweepThreshold {
+			for k, b := range l.keys {
+				if b.idle(now) {
+					delete(l.keys, k)
+				}
+			}
+		}
+		b = newBucket(l.perKey)
+		l.keys[key] = b
+	}
+	return b
+}
+
+// ChatKey - limit key of a message recipient: the chat if chatID is set, otherwise the user login.
+func ChatKey(chatID, login string) string {
+	switch {
+	case chatID != "":
+		return "chat:" + chatID
+	case login != "":
+		return "login:" + login
+	}
+	return ""
+}
+
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(r Rate) *bucket {
+	if r.PerSecond <= 0 {
+		return nil
+	}
+	burst := float64(max(r.Burst, 1))
+	return &bucket{
+		rate:   r.PerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve - takes a token, possibly in debt, and returns how long to wait until it is really available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+1, b.burst)
+}
+
+func (b *bucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	return b.tokens >= b.burst
+}
+
+func (b *bucket) advance(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+		b.last = now
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy - policy of repeating requests that failed with a network error, 429 or 5xx status.
+// Only requests with Request.Retry set are repeated.
+// MaxAttempts - maximum number of attempts including the first one. Values less than 2 disable retries.
+// MinDelay - delay before the second attempt. Every next delay is twice as long.
+// MaxDelay - upper bound of the delay. Retry-After of the response is respected even if it is longer.
+// Jitter - fraction of the delay in [0, 1] that is randomized to spread retries of different clients.
+type RetryPolicy struct {
+	MaxAttempts int
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// DefaultRetryPolicy - policy used by the client unless another one is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		MinDelay:    500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// NoRetry - policy that makes a single attempt.
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// Middleware - repeats requests according to the policy.
+// The response of the last attempt is returned as is.
+func (p RetryPolicy) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			for attempt := 1; ; attempt++ {
+				resp, err := next(ctx, req)
+				if !req.Retry || attempt >= p.MaxAttempts || !retryable(resp, err) || ctx.Err() != nil {
+					return resp, err
+				}
+
+				delay := p.delay(attempt)
+				if resp != nil {
+					if ra := retryAfter(resp.Header.Get("Retry-After")); ra > delay {
+						delay = ra
+					}
+				}
+				t := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return nil, ctx.Err()
+				case <-t.C:
+				}
+			}
+		}
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MinDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		spread := time.Duration(float64(d) * min(p.Jitter, 1))
+		d = d - spread + rand.N(2*spread+1)
+	}
+	return d
+}
+
+func retryable(resp *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
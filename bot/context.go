@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/messages"
+	"github.com/Liriker/YaMa/router"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"strconv"
+)
+
+// Context - update being handled with helpers to answer it.
+// The helpers address the answer to the chat of the update: by chat ID in group chats and channels,
+// and by the sender's login in private chats, where the chat ID is not meaningful.
+type Context struct {
+	context.Context
+	Update   types.Update
+	messages *messages.Client
+}
+
+func NewContext(ctx context.Context, update types.Update, m *messages.Client) *Context {
+	return &Context{
+		Context:  ctx,
+		Update:   update,
+		messages: m,
+	}
+}
+
+// Handle - adapts a function that works with Context to updates.Handler.
+func Handle(m *messages.Client, f func(c *Context) error) updates.Handler {
+	return updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+		return f(NewContext(ctx, update, m))
+	})
+}
+
+// Command - command of the update when it is handled by a router.Router.
+func (c *Context) Command() (router.Command, bool) {
+	return router.CommandFromContext(c)
+}
+
+// Recipient - chat ID and login to address an answer to the chat of the update. Only one of them is set.
+func (c *Context) Recipient() (chatID, login string) {
+	if c.Update.Chat.Type == types.PrivateChatType || c.Update.Chat.ID == "" {
+		return "", c.Update.From.Login
+	}
+	return c.Update.Chat.ID, ""
+}
+
+// Send - sends the message to the chat of the update, filling in ChatID and Login if both are empty.
+func (c *Context) Send(message types.NewMessage) (int64, error) {
+	if message.ChatID == "" && message.Login == "" {
+		message.ChatID, message.Login = c.Recipient()
+	}
+	return c.messages.SendCtx(c, message)
+}
+
+// Reply - answers the update with text quoting its message, if it has one. In a thread the answer stays in the thread.
+func (c *Context) Reply(text string) (int64, error) {
+	message := types.NewMessage{
+		Text:     text,
+		ThreadID: c.threadID(false),
+	}
+	if c.Update.MessageID != 0 {
+		message.ReplyMessageID = strconv.FormatInt(c.Update.MessageID, 10)
+	}
+	return c.Send(message)
+}
+
+// ReplyInThread - answers in the thread of the update message, starting the thread if there is none.
+func (c *Context) ReplyInThread(text string) (int64, error) {
+	return c.Send(types.NewMessage{
+		Text:     text,
+		ThreadID: c.threadID(true),
+	})
+}
+
+// ReplyFile - sends a file to the chat of the update, in the thread if the update is in one.
+func (c *Context) ReplyFile(data []byte, filename string) (int64, error) {
+	chatID, login := c.Recipient()
+	return c.messages.SendFileCtx(c, types.NewFileMessage{
+		ChatID:   chatID,
+		Login:    login,
+		Document: data,
+		ThreadID: c.Update.ThreadID,
+	}, filename)
+}
+
+// ReplyImage - sends an image to the chat of the update, in the thread if the update is in one.
+func (c *Context) ReplyImage(data []byte, filename string) (int64, error) {
+	chatID, login := c.Recipient()
+	return c.messages.SendImageCtx(c, types.NewImageMessage{
+		ChatID:   chatID,
+		Login:    login,
+		Image:    data,
+		ThreadID: c.Update.ThreadID,
+	}, filename)
+}
+
+// Delete - deletes the message of the update.
+func (c *Context) Delete() error {
+	chatID, login := c.Recipient()
+	_, err := c.messages.DeleteCtx(c, types.NewDeleteMessageRequest{
+		ChatID:    chatID,
+		Login:     login,
+		MessageID: c.Update.MessageID,
+		ThreadID:  c.Update.ThreadID,
+	})
+	return err
+}
+
+// threadID - thread of the update; if start is set and the update is not in a thread, the thread of its message.
+func (c *Context) threadID(start bool) string {
+	id := c.Update.ThreadID
+	if id == 0 && start {
+		id = c.Update.MessageID
+	}
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,65 @@
+package bot_test
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/bot"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/yamatest"
+	"testing"
+)
+
+func TestContextAddressesReplies(t *testing.T) {
+	private := types.Update{MessageID: 11, From: types.Sender{Login: "user"}, Chat: types.Chat{Type: types.PrivateChatType}}
+	group := types.Update{MessageID: 12, From: types.Sender{Login: "user"}, Chat: types.Chat{Type: types.GroupChatType, ID: "chat"}}
+	thread := group
+	thread.MessageID, thread.ThreadID = 13, 100
+	noMessage := types.Update{From: types.Sender{Login: "user"}, Chat: types.Chat{Type: types.PrivateChatType}}
+
+	tests := []struct {
+		name   string
+		update types.Update
+		send   func(c *bot.Context) error
+		want   yamatest.SentMessage
+	}{
+		{"private reply", private, reply, yamatest.SentMessage{Login: "user", ReplyMessageID: "11"}},
+		{"group reply", group, reply, yamatest.SentMessage{ChatID: "chat", ReplyMessageID: "12"}},
+		{"reply in thread", thread, reply, yamatest.SentMessage{ChatID: "chat", ReplyMessageID: "13", ThreadID: "100"}},
+		{"reply without message", noMessage, reply, yamatest.SentMessage{Login: "user"}},
+		{"start thread", group, replyInThread, yamatest.SentMessage{ChatID: "chat", ThreadID: "12"}},
+		{"continue thread", thread, replyInThread, yamatest.SentMessage{ChatID: "chat", ThreadID: "100"}},
+		{"send to explicit login", group, func(c *bot.Context) error {
+			_, err := c.Send(types.NewMessage{Login: "other", Text: "text"})
+			return err
+		}, yamatest.SentMessage{Login: "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := yamatest.NewServer("token")
+			defer srv.Close()
+			c := bot.NewContext(context.Background(), tt.update, srv.NewClient().Messages)
+			if err := tt.send(c); err != nil {
+				t.Fatal(err)
+			}
+			sent := srv.Sent()
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+			got := sent[0]
+			if got.ChatID != tt.want.ChatID || got.Login != tt.want.Login ||
+				got.ReplyMessageID != tt.want.ReplyMessageID || got.ThreadID != tt.want.ThreadID || got.Text != "text" {
+				t.Fatalf("sent chat %q, login %q, reply to %q, thread %q, text %q, want %+v",
+					got.ChatID, got.Login, got.ReplyMessageID, got.ThreadID, got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func reply(c *bot.Context) error {
+	_, err := c.Reply("text")
+	return err
+}
+
+func replyInThread(c *bot.Context) error {
+	_, err := c.ReplyInThread("text")
+	return err
+}
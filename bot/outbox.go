@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/messages"
+	"github.com/Liriker/YaMa/types"
+	"sync"
+)
+
+// ErrOutboxClosed - returned by Outbox.Send after the outbox is shut down.
+var ErrOutboxClosed = errors.New("outbox is closed")
+
+// Outbox - queue of text messages sent in the background, e.g. notifications that should not block handlers.
+// Messages queued before the runner stops are sent before it returns.
+type Outbox struct {
+	messages *messages.Client
+	onError  func(message types.NewMessage, err error)
+
+	queue     chan types.NewMessage
+	mu        sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	base      context.Context
+	cancel    context.CancelFunc
+}
+
+func newOutbox(m *messages.Client, size int, onError func(types.NewMessage, error)) *Outbox {
+	o := &Outbox{
+		messages: m,
+		onError:  onError,
+		queue:    make(chan types.NewMessage, size),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	o.base, o.cancel = context.WithCancel(context.Background())
+	go o.run()
+	return o
+}
+
+// Send - queues the message, blocking while the queue is full.
+func (o *Outbox) Send(ctx context.Context, message types.NewMessage) error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return ErrOutboxClosed
+	}
+	select {
+	case o.queue <- message:
+		return nil
+	case <-o.closing:
+		return ErrOutboxClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending - number of queued messages.
+func (o *Outbox) Pending() int {
+	return len(o.queue)
+}
+
+// shutdown - stops accepting messages and waits until the queued ones are sent.
+// If ctx is done first, the message being sent is cancelled and the rest are dropped.
+func (o *Outbox) shutdown(ctx context.Context) error {
+	o.closeOnce.Do(func() {
+		close(o.closing)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.closed = true
+		close(o.queue)
+	})
+	select {
+	case <-o.done:
+		return nil
+	case <-ctx.Done():
+		o.cancel()
+		<-o.done
+		return ctx.Err()
+	}
+}
+
+func (o *Outbox) run() {
+	defer close(o.done)
+	for message := range o.queue {
+		if o.base.Err() != nil {
+			o.onError(message, o.base.Err())
+			continue
+		}
+		if _, err := o.messages.SendCtx(o.base, message); err != nil {
+			o.onError(message, err)
+		}
+	}
+}
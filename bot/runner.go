@@ -0,0 +1,255 @@
+// Package bot runs a bot: receives updates, handles them concurrently and stops gracefully.
+package bot
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/dispatch"
+	"github.com/Liriker/YaMa/polling"
+	"github.com/Liriker/YaMa/transport"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 10 * time.Second
+	defaultCommitInterval  = time.Second
+	defaultOutboxSize      = 256
+)
+
+// Runner - bot runtime. It polls updates (or reads them from another updates.Source), handles them with
+// a dispatch.Dispatcher and on cancellation of the context or a termination signal:
+// stops fetching new updates, waits for in-flight handlers and queued outbound messages until the shutdown
+// timeout, then cancels the running ones, drops the queued ones and waits until they stop, commits the offset
+// of the last handled update and only then returns.
+type Runner struct {
+	client          *transport.Client
+	handler         updates.Handler
+	source          updates.Source
+	store           polling.OffsetStore
+	pollingOpts     []polling.Option
+	dispatchOpts    []dispatch.Option
+	signals         []os.Signal
+	shutdownTimeout time.Duration
+	commitInterval  time.Duration
+	onError         func(ctx context.Context, update types.Update, err error)
+	commitFailed    bool
+	outbox          *Outbox
+
+	mu      sync.Mutex
+	pending map[int64]int
+	poller  *polling.Client
+}
+
+// Option - configures Runner.
+type Option func(*Runner)
+
+// WithSource - source of updates instead of polling, e.g. webhook.Source. The offset store is not used with it.
+func WithSource(src updates.Source) Option {
+	return func(r *Runner) {
+		r.source = src
+	}
+}
+
+// WithOffsetStore - store of the polling offset. Only offsets of handled updates are committed to it:
+// the committed offset does not pass updates that are queued, running, dropped on shutdown or failed
+// (unless WithCommitFailed is set), so after a restart they are handled again together with the updates after them.
+func WithOffsetStore(store polling.OffsetStore) Option {
+	return func(r *Runner) {
+		r.store = store
+	}
+}
+
+// WithPollingOptions - options of the polling client. The offset and the offset store are managed by Runner.
+func WithPollingOptions(opts ...polling.Option) Option {
+	return func(r *Runner) {
+		r.pollingOpts = append(r.pollingOpts, opts...)
+	}
+}
+
+// WithDispatchOptions - options of the dispatcher: number of workers, queue size.
+func WithDispatchOptions(opts ...dispatch.Option) Option {
+	return func(r *Runner) {
+		r.dispatchOpts = append(r.dispatchOpts, opts...)
+	}
+}
+
+// WithCommitFailed - commits the offset past updates whose handler returned an error, so they are not handled
+// again after a restart. By default they stay pending until the runner stops.
+func WithCommitFailed() Option {
+	return func(r *Runner) {
+		r.commitFailed = true
+	}
+}
+
+// WithSignals - signals that stop the runner. Default is SIGINT and SIGTERM.
+func WithSignals(signals ...os.Signal) Option {
+	return func(r *Runner) {
+		r.signals = signals
+	}
+}
+
+// WithShutdownTimeout - how long to wait for in-flight handlers and outbound messages. Default is 10 seconds.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.shutdownTimeout = d
+	}
+}
+
+// WithCommitInterval - how often the offset is committed while running. Default is 1 second.
+func WithCommitInterval(d time.Duration) Option {
+	return func(r *Runner) {
+		r.commitInterval = d
+	}
+}
+
+// WithErrorHandler - function called when the handler returns an error, an outbound message is not sent
+// or getUpdates fails and is going to be repeated. For outbound messages and getUpdates update is empty.
+func WithErrorHandler(f func(ctx context.Context, update types.Update, err error)) Option {
+	return func(r *Runner) {
+		r.onError = f
+	}
+}
+
+func NewRunner(client *transport.Client, handler updates.Handler, opts ...Option) *Runner {
+	r := &Runner{
+		client:          client,
+		handler:         handler,
+		signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+		shutdownTimeout: defaultShutdownTimeout,
+		commitInterval:  defaultCommitInterval,
+		onError:         func(context.Context, types.Update, error) {},
+		pending:         map[int64]int{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.outbox = newOutbox(client.Messages, defaultOutboxSize, func(m types.NewMessage, err error) {
+		r.onError(context.Background(), types.Update{}, err)
+	})
+	return r
+}
+
+// Outbox - queue of messages sent in the background and drained on shutdown.
+func (r *Runner) Outbox() *Outbox {
+	return r.outbox
+}
+
+// Run - runs the bot until ctx is cancelled or a signal is received, then shuts it down gracefully.
+// Runner can be run only once.
+// It returns nil after a clean shutdown, the error of the update source, or an error if the shutdown timed out
+// or the offset could not be committed.
+func (r *Runner) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, r.signals...)
+	defer stop()
+
+	var offset int64
+	if r.store != nil && r.source == nil {
+		var err error
+		if offset, err = r.store.Load(ctx); err != nil {
+			return err
+		}
+	}
+
+	d := dispatch.New(updates.HandlerFunc(r.handle), append([]dispatch.Option{
+		dispatch.WithErrorHandler(r.onError),
+	}, r.dispatchOpts...)...)
+	enqueue := updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+		r.track(update.UpdateID)
+		if err := d.HandleUpdate(ctx, update); err != nil {
+			r.untrack(update.UpdateID)
+			return err
+		}
+		return nil
+	})
+
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	commitDone := make(chan struct{})
+	var fetchErr error
+	if r.source != nil {
+		close(commitDone)
+		fetchErr = updates.Serve(fetchCtx, r.source, enqueue)
+	} else {
+		pollingOpts := append([]polling.Option{polling.WithErrorHandler(func(ctx context.Context, err error) {
+			r.onError(ctx, types.Update{}, err)
+		})}, r.pollingOpts...)
+		r.poller = polling.NewClient(r.client.Updates, append(pollingOpts, polling.WithOffset(offset))...)
+		go r.commitLoop(fetchCtx, commitDone)
+		fetchErr = r.poller.Run(fetchCtx, enqueue)
+	}
+	stopFetch()
+	<-commitDone
+	if ctx.Err() != nil && errors.Is(fetchErr, ctx.Err()) {
+		fetchErr = nil
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.shutdownTimeout)
+	defer cancel()
+	errs := []error{fetchErr}
+	errs = append(errs, d.Shutdown(shutdownCtx))
+	errs = append(errs, r.outbox.shutdown(shutdownCtx))
+	errs = append(errs, r.commit(context.Background()))
+	return errors.Join(errs...)
+}
+
+func (r *Runner) handle(ctx context.Context, update types.Update) error {
+	err := r.handler.HandleUpdate(ctx, update)
+	// ctx is cancelled only when the shutdown timed out: such updates always stay pending.
+	if ctx.Err() == nil && (err == nil || r.commitFailed) {
+		r.untrack(update.UpdateID)
+	}
+	return err
+}
+
+func (r *Runner) track(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[id]++
+}
+
+func (r *Runner) untrack(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[id]--; r.pending[id] <= 0 {
+		delete(r.pending, id)
+	}
+}
+
+// safeOffset - offset below which all updates are handled: the lowest pending update or the polling offset.
+func (r *Runner) safeOffset() int64 {
+	offset := r.poller.Offset()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.pending {
+		offset = min(offset, id)
+	}
+	return offset
+}
+
+func (r *Runner) commit(ctx context.Context) error {
+	if r.store == nil || r.poller == nil {
+		return nil
+	}
+	return r.store.Save(ctx, r.safeOffset())
+}
+
+func (r *Runner) commitLoop(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTicker(r.commitInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := r.commit(ctx); err != nil {
+				r.onError(ctx, types.Update{}, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package bot_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/bot"
+	"github.com/Liriker/YaMa/polling"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"github.com/Liriker/YaMa/yamatest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunnerCommitsHandledUpdates(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	for _, text := range []string{"one", "two", "three"} {
+		srv.InjectText("user", text)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var handled atomic.Int64
+	store := &polling.MemoryOffsetStore{}
+	r := bot.NewRunner(srv.NewClient(), updates.HandlerFunc(func(context.Context, types.Update) error {
+		if handled.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	}), bot.WithOffsetStore(store), bot.WithPollingOptions(polling.WithIdleTimeout(time.Millisecond)))
+
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := store.Load(context.Background()); offset != 4 {
+		t.Fatalf("committed offset %d, want 4", offset)
+	}
+}
+
+func TestRunnerKeepsPendingUpdatesUncommitted(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	srv.InjectGroupText("slow", "user", "stuck")
+	srv.InjectGroupText("fast", "user", "one")
+	srv.InjectGroupText("fast", "user", "two")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var fast atomic.Int64
+	store := &polling.MemoryOffsetStore{}
+	r := bot.NewRunner(srv.NewClient(), updates.HandlerFunc(func(ctx context.Context, u types.Update) error {
+		if u.Chat.ID == "slow" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if fast.Add(1) == 2 {
+			cancel()
+		}
+		return nil
+	}),
+		bot.WithOffsetStore(store),
+		bot.WithShutdownTimeout(50*time.Millisecond),
+		bot.WithPollingOptions(polling.WithIdleTimeout(time.Millisecond)),
+	)
+
+	if err := r.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() = %v, want DeadlineExceeded of the shutdown", err)
+	}
+	if offset, _ := store.Load(context.Background()); offset != 1 {
+		t.Fatalf("committed offset %d, want 1 (the pending update)", offset)
+	}
+}
+
+func TestRunnerKeepsFailedUpdatesUncommitted(t *testing.T) {
+	for _, commitFailed := range []bool{false, true} {
+		srv := yamatest.NewServer("token")
+		for _, text := range []string{"one", "two", "three"} {
+			srv.InjectGroupText(text, "user", text)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var handled atomic.Int64
+		store := &polling.MemoryOffsetStore{}
+		opts := []bot.Option{bot.WithOffsetStore(store), bot.WithPollingOptions(polling.WithIdleTimeout(time.Millisecond))}
+		if commitFailed {
+			opts = append(opts, bot.WithCommitFailed())
+		}
+		r := bot.NewRunner(srv.NewClient(), updates.HandlerFunc(func(_ context.Context, u types.Update) error {
+			defer func() {
+				if handled.Add(1) == 3 {
+					cancel()
+				}
+			}()
+			if u.Text == "two" {
+				return errors.New("failed")
+			}
+			return nil
+		}), opts...)
+
+		if err := r.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := int64(2)
+		if commitFailed {
+			want = 4
+		}
+		if offset, _ := store.Load(context.Background()); offset != want {
+			t.Errorf("commitFailed %v: committed offset %d, want %d", commitFailed, offset, want)
+		}
+		cancel()
+		srv.Close()
+	}
+}
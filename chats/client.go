@@ -1,29 +1,25 @@
 package chats
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
+	"github.com/Liriker/YaMa/api"
 	"github.com/Liriker/YaMa/types"
 	"net/http"
 )
 
 const (
-	createUrl   = "https://botapi.messenger.yandex.net/bot/v1/chats/create/"
-	updateUrl   = "https://botapi.messenger.yandex.net/bot/v1/chats/updateMembers/"
-	userLinkUrl = "https://botapi.messenger.yandex.net/bot/v1/users/getUserLink/"
+	createPath   = "chats/create/"
+	updatePath   = "chats/updateMembers/"
+	userLinkPath = "users/getUserLink/"
 )
 
 type Client struct {
-	client  *http.Client
-	headers http.Header
+	executor *api.Executor
 }
 
-func NewClient(cl *http.Client, h http.Header) *Client {
+func NewClient(e *api.Executor) *Client {
 	return &Client{
-		client:  cl,
-		headers: h,
+		executor: e,
 	}
 }
 
@@ -34,35 +30,20 @@ func NewClient(cl *http.Client, h http.Header) *Client {
 // The bot becomes the administrator of the created chat (channel).
 // The bot cannot add a participant to the chat for whom this is prohibited by the privacy settings.
 func (c *Client) Create(chat types.NewChat) (string, error) {
-	data, err := json.Marshal(chat)
-	if err != nil {
-		return "", err
-	}
-	body := bytes.NewBuffer(data)
-
-	req, err := http.NewRequest(http.MethodPost, createUrl, body)
-	if err != nil {
-		return "", err
-	}
+	return c.CreateCtx(context.Background(), chat)
+}
 
-	req.Header = c.headers
-	respData, err := c.client.Do(req)
+// CreateCtx - same as Create, the request is bound to ctx (cancellation, deadline).
+func (c *Client) CreateCtx(ctx context.Context, chat types.NewChat) (string, error) {
+	req, err := api.NewJSONRequest(http.MethodPost, createPath, chat)
 	if err != nil {
 		return "", err
 	}
 	resp := response{}
-	err = json.NewDecoder(respData.Body).Decode(&resp)
-	if err != nil {
-		return "", errors.New(fmt.Sprintf("%v", respData))
-	}
-	if !resp.Ok {
-		return "", errors.New(fmt.Sprint(resp.Description))
-	}
-	if respData.StatusCode != http.StatusOK {
-		return "", errors.New(fmt.Sprint(respData))
+	if err := c.executor.Do(ctx, req, &resp); err != nil {
+		return "", err
 	}
 	return resp.ChatID, nil
-
 }
 
 // Update - The method allows you to add and remove participants to the chat, add and remove subscribers to the channel, as well as appoint chat or channel administrators.
@@ -70,61 +51,33 @@ func (c *Client) Create(chat types.NewChat) (string, error) {
 // На момент написания почему-то запрос, соответствующий документации выдаёт ошибку invalid_request, что поле "login" является обязательным, хотя оно есть.
 // TODO - проверить отправку запроса
 func (c *Client) Update(update *types.ChatUpdate) error {
-	data, err := json.Marshal(update)
-	if err != nil {
-		return err
-	}
-	body := bytes.NewBuffer(data)
-	req, err := http.NewRequest(http.MethodPost, updateUrl, body)
-	if err != nil {
-		return err
-	}
-	req.Header = c.headers
+	return c.UpdateCtx(context.Background(), update)
+}
 
-	respData, err := c.client.Do(req)
+// UpdateCtx - same as Update, the request is bound to ctx (cancellation, deadline).
+func (c *Client) UpdateCtx(ctx context.Context, update *types.ChatUpdate) error {
+	req, err := api.NewJSONRequest(http.MethodPost, updatePath, update)
 	if err != nil {
 		return err
 	}
-	resp := response{}
-	err = json.NewDecoder(respData.Body).Decode(&resp)
-	if err != nil {
-		return errors.New(fmt.Sprint(respData))
-	}
-	if !resp.Ok {
-		return errors.New(fmt.Sprint(resp.Description))
-	}
-	return nil
+	req.LimitKey = api.ChatKey(update.ChatID, "")
+	return c.executor.Do(ctx, req, nil)
 }
 
 func (c *Client) GetUserLinks(user types.User) (*UserLinkResponse, error) {
-	data, err := json.Marshal(user.Login)
-	if err != nil {
-		return nil, err
-	}
-	body := bytes.NewBuffer(data)
-	req, err := http.NewRequest(http.MethodGet, userLinkUrl, body)
-	if err != nil {
-		return nil, err
-	}
-	headers := c.headers.Clone()
-	headers.Del("Content-Type")
-	req.Header = headers
-	respData, err := c.client.Do(req)
+	return c.GetUserLinksCtx(context.Background(), user)
+}
+
+// GetUserLinksCtx - same as GetUserLinks, the request is bound to ctx (cancellation, deadline).
+func (c *Client) GetUserLinksCtx(ctx context.Context, user types.User) (*UserLinkResponse, error) {
+	req, err := api.NewJSONRequest(http.MethodGet, userLinkPath, user.Login)
 	if err != nil {
 		return nil, err
 	}
+	req.Header = http.Header{"Content-Type": {""}}
 	resp := UserLinkResponse{}
-	err = json.NewDecoder(respData.Body).Decode(&resp)
-	if err != nil {
-		return nil, errors.New(fmt.Sprint(respData))
-	}
-	if !resp.Ok {
-		response := response{}
-		err := json.NewDecoder(respData.Body).Decode(&response)
-		if err != nil {
-			return nil, errors.New(fmt.Sprintf("%v", respData))
-		}
-		return nil, errors.New(fmt.Sprintf("%v", response))
+	if err := c.executor.Do(ctx, req, &resp); err != nil {
+		return nil, err
 	}
 	return &resp, nil
 }
@@ -3,11 +3,17 @@ package YaMa
 import (
 	"errors"
 	"github.com/Liriker/YaMa/transport"
+	"net/url"
 )
 
-func NewClient(token string) (*transport.Client, error) {
+// NewClient - creates Bot API client authorized with token. See transport.Option for available options.
+func NewClient(token string, opts ...transport.Option) (*transport.Client, error) {
 	if token == "" {
 		return nil, errors.New("token is empty")
 	}
-	return transport.NewClient(token), nil
+	cl := transport.NewClient(token, opts...)
+	if _, err := url.ParseRequestURI(cl.BaseURL()); err != nil {
+		return nil, err
+	}
+	return cl, nil
 }
@@ -0,0 +1,75 @@
+// Package dedup drops updates that reach the handler more than once because of retries,
+// webhook redelivery or polling restarts.
+package dedup
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"strconv"
+	"sync/atomic"
+)
+
+// Store - set of keys of seen updates. Implementations shared by several bot instances make deduplication global.
+// Seen atomically marks key as seen and reports whether it had been seen before.
+// Forget removes key, so the update is accepted again.
+type Store interface {
+	Seen(ctx context.Context, key string) (bool, error)
+	Forget(ctx context.Context, key string) error
+}
+
+// Handler - updates.Handler that passes every update to the next handler only once.
+// If the next handler fails, the update is forgotten, so its redelivery is handled again.
+type Handler struct {
+	next    updates.Handler
+	store   Store
+	dropped atomic.Int64
+}
+
+func New(next updates.Handler, store Store) *Handler {
+	return &Handler{
+		next:  next,
+		store: store,
+	}
+}
+
+func (h *Handler) HandleUpdate(ctx context.Context, update types.Update) error {
+	key := Key(update)
+	if key == "" {
+		return h.next.HandleUpdate(ctx, update)
+	}
+	seen, err := h.store.Seen(ctx, key)
+	if err != nil {
+		return err
+	}
+	if seen {
+		h.dropped.Add(1)
+		return nil
+	}
+	if err := h.next.HandleUpdate(ctx, update); err != nil {
+		_ = h.store.Forget(context.WithoutCancel(ctx), key)
+		return err
+	}
+	return nil
+}
+
+// Dropped - number of duplicate updates dropped.
+func (h *Handler) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Key - key of the update: its UpdateID, or the chat and MessageID if UpdateID is not set.
+// Empty key means the update can't be identified and is never dropped.
+func Key(update types.Update) string {
+	if update.UpdateID != 0 {
+		return "update:" + strconv.FormatInt(update.UpdateID, 10)
+	}
+	if update.MessageID == 0 {
+		return ""
+	}
+	chat := update.Chat.ID
+	if update.Chat.Type == types.PrivateChatType || chat == "" {
+		chat = "login:" + update.From.Login
+	}
+	return "message:" + chat + ":" + strconv.FormatInt(update.MessageID, 10)
+}
@@ -0,0 +1,110 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"testing"
+	"time"
+)
+
+func seen(t *testing.T, s Store, key string) bool {
+	t.Helper()
+	ok, err := s.Seen(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestMemoryStoreEvictsLeastRecentlySeen(t *testing.T) {
+	s := NewMemoryStore(2, 0)
+	seen(t, s, "a")
+	seen(t, s, "b")
+	if !seen(t, s, "a") {
+		t.Fatal("a is not seen")
+	}
+	seen(t, s, "c") // evicts b, the least recently seen
+	if s.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", s.Len())
+	}
+	if !seen(t, s, "a") || !seen(t, s, "c") {
+		t.Fatal("a or c is evicted")
+	}
+	if seen(t, s, "b") {
+		t.Fatal("b is not evicted")
+	}
+}
+
+func TestMemoryStoreExpires(t *testing.T) {
+	s := NewMemoryStore(10, 20*time.Millisecond)
+	seen(t, s, "a")
+	if !seen(t, s, "a") {
+		t.Fatal("a is not seen")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if seen(t, s, "a") {
+		t.Fatal("a is seen after ttl")
+	}
+	if !seen(t, s, "a") {
+		t.Fatal("a is not seen again after it was re-added")
+	}
+}
+
+func TestHandlerDropsDuplicatesAndForgetsFailures(t *testing.T) {
+	var calls int
+	fail := true
+	h := New(updates.HandlerFunc(func(context.Context, types.Update) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}), NewMemoryStore(10, 0))
+	ctx := context.Background()
+	u := types.Update{UpdateID: 1}
+
+	if err := h.HandleUpdate(ctx, u); err == nil {
+		t.Fatal("HandleUpdate() error is lost")
+	}
+	fail = false
+	if err := h.HandleUpdate(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.HandleUpdate(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || h.Dropped() != 1 {
+		t.Fatalf("handled %d times, dropped %d, want 2 and 1", calls, h.Dropped())
+	}
+
+	// Updates without any ID are never dropped.
+	for i := 0; i < 2; i++ {
+		if err := h.HandleUpdate(ctx, types.Update{Text: "no id"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 4 || h.Dropped() != 1 {
+		t.Fatalf("handled %d times, dropped %d, want 4 and 1", calls, h.Dropped())
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		update types.Update
+		want   string
+	}{
+		{"update ID", types.Update{UpdateID: 7, MessageID: 3}, "update:7"},
+		{"group message", types.Update{MessageID: 3, Chat: types.Chat{Type: types.GroupChatType, ID: "chat"}}, "message:chat:3"},
+		{"private message", types.Update{MessageID: 3, Chat: types.Chat{Type: types.PrivateChatType, ID: "x"}, From: types.Sender{Login: "user"}}, "message:login:user:3"},
+		{"no chat ID", types.Update{MessageID: 3, From: types.Sender{Login: "user"}}, "message:login:user:3"},
+		{"no IDs", types.Update{Text: "hello"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.update); got != tt.want {
+			t.Errorf("%s: Key() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - Store that keeps up to size keys for ttl in memory, evicting the least recently seen keys first.
+type MemoryStore struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryStore - creates MemoryStore. Zero ttl means keys don't expire and are only evicted by size.
+func NewMemoryStore(size int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		size:  max(size, 1),
+		ttl:   ttl,
+		order: list.New(),
+		keys:  map[string]*list.Element{},
+	}
+}
+
+func (s *MemoryStore) Seen(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if el, ok := s.keys[key]; ok {
+		e := el.Value.(*entry)
+		if s.ttl == 0 || now.Before(e.expires) {
+			s.order.MoveToFront(el)
+			return true, nil
+		}
+		s.remove(el)
+	}
+
+	el := s.order.PushFront(&entry{key: key, expires: now.Add(s.ttl)})
+	s.keys[key] = el
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) Forget(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.keys[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len - number of stored keys, including expired ones that are not evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.keys, el.Value.(*entry).key)
+}
@@ -0,0 +1,194 @@
+// Package dispatch handles updates concurrently while keeping their order within every chat.
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	defaultWorkers   = 8
+	defaultQueueSize = 64
+)
+
+var (
+	// ErrClosed - returned by HandleUpdate after Shutdown.
+	ErrClosed = errors.New("dispatcher is closed")
+	// ErrDropped - reported to the error handler for queued updates that were not handled because Shutdown timed out.
+	ErrDropped = errors.New("update dropped on shutdown")
+)
+
+type job struct {
+	ctx    context.Context
+	update types.Update
+}
+
+// Dispatcher - updates.Handler that shards updates by chat onto a pool of workers.
+// Updates of one chat are handled by the same worker in the order they were received, different chats run in parallel.
+// Every worker has a bounded queue; when it is full, HandleUpdate blocks, slowing down the source of updates.
+// HandleUpdate returns as soon as the update is queued, handler errors are reported to the error handler.
+type Dispatcher struct {
+	handler   updates.Handler
+	workers   int
+	queueSize int
+	onError   func(ctx context.Context, update types.Update, err error)
+
+	shards    []chan job
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+	base      context.Context
+	cancel    context.CancelFunc
+}
+
+// Option - configures Dispatcher.
+type Option func(*Dispatcher)
+
+// WithWorkers - number of workers. Default is 8.
+func WithWorkers(n int) Option {
+	return func(d *Dispatcher) {
+		d.workers = n
+	}
+}
+
+// WithQueueSize - number of updates queued for every worker. Default is 64.
+func WithQueueSize(n int) Option {
+	return func(d *Dispatcher) {
+		d.queueSize = n
+	}
+}
+
+// WithErrorHandler - function called when the handler returns an error.
+func WithErrorHandler(f func(ctx context.Context, update types.Update, err error)) Option {
+	return func(d *Dispatcher) {
+		d.onError = f
+	}
+}
+
+// New - creates Dispatcher and starts its workers. Stop it with Shutdown.
+func New(handler updates.Handler, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		handler:   handler,
+		workers:   defaultWorkers,
+		queueSize: defaultQueueSize,
+		onError:   func(context.Context, types.Update, error) {},
+		closing:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.workers = max(d.workers, 1)
+	d.queueSize = max(d.queueSize, 0)
+	d.base, d.cancel = context.WithCancel(context.Background())
+
+	d.shards = make([]chan job, d.workers)
+	for i := range d.shards {
+		d.shards[i] = make(chan job, d.queueSize)
+		d.wg.Add(1)
+		go d.work(d.shards[i])
+	}
+	return d
+}
+
+// HandleUpdate - queues the update to the worker of its chat.
+// The handler gets a context with the values of ctx, which is not cancelled when ctx is, but is cancelled
+// when Shutdown gives up waiting.
+func (d *Dispatcher) HandleUpdate(ctx context.Context, update types.Update) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrClosed
+	}
+	select {
+	case d.shards[d.shard(update)] <- job{ctx: context.WithoutCancel(ctx), update: update}:
+		return nil
+	case <-d.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown - stops accepting updates and waits until the queued and running ones are handled.
+// If ctx is done first, contexts of the running handlers are cancelled, the queued updates are dropped
+// and reported to the error handler with ErrDropped, and the error of ctx is returned after the running
+// handlers have returned. Handlers must return when their context is cancelled.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.closeOnce.Do(func() {
+		close(d.closing)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.closed = true
+		for _, shard := range d.shards {
+			close(shard)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Pending - number of queued updates that are not taken by workers yet.
+func (d *Dispatcher) Pending() int {
+	n := 0
+	for _, shard := range d.shards {
+		n += len(shard)
+	}
+	return n
+}
+
+func (d *Dispatcher) work(queue <-chan job) {
+	defer d.wg.Done()
+	for j := range queue {
+		ctx, cancel := context.WithCancel(j.ctx)
+		stop := context.AfterFunc(d.base, cancel)
+		// Checked after AfterFunc is registered: from here on cancellation of base reaches ctx.
+		if d.base.Err() != nil {
+			stop()
+			cancel()
+			d.onError(j.ctx, j.update, ErrDropped)
+			continue
+		}
+		if err := d.handler.HandleUpdate(ctx, j.update); err != nil {
+			d.onError(ctx, j.update, err)
+		}
+		stop()
+		cancel()
+	}
+}
+
+func (d *Dispatcher) shard(update types.Update) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(ShardKey(update)))
+	return int(h.Sum32() % uint32(len(d.shards)))
+}
+
+// ShardKey - key that defines the order of updates: the chat ID, or the sender for private chats,
+// where the chat ID is not meaningful.
+func ShardKey(update types.Update) string {
+	if update.Chat.Type != types.PrivateChatType && update.Chat.ID != "" {
+		return "chat:" + update.Chat.ID
+	}
+	if update.From.Login != "" {
+		return "login:" + update.From.Login
+	}
+	return "id:" + update.From.ID
+}
@@ -0,0 +1,148 @@
+package dispatch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Liriker/YaMa/dispatch"
+	"github.com/Liriker/YaMa/polling"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"github.com/Liriker/YaMa/yamatest"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrderPerChat(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	const chats, perChat = 5, 20
+	for i := 0; i < perChat; i++ {
+		for c := 0; c < chats; c++ {
+			text := fmt.Sprint(i)
+			if c%2 == 0 {
+				srv.InjectGroupText(fmt.Sprintf("chat%d", c), "user", text)
+			} else {
+				srv.InjectText(fmt.Sprintf("user%d", c), text)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	total := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d := dispatch.New(updates.HandlerFunc(func(_ context.Context, u types.Update) error {
+		time.Sleep(time.Duration(rand.IntN(500)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		key := dispatch.ShardKey(u)
+		handled[key] = append(handled[key], u.Text)
+		if total++; total == chats*perChat {
+			cancel()
+		}
+		return nil
+	}), dispatch.WithWorkers(3), dispatch.WithQueueSize(2))
+
+	p := polling.NewClient(srv.NewClient().Updates, polling.WithIdleTimeout(time.Millisecond))
+	if err := p.Run(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(handled) != chats {
+		t.Fatalf("handled %d shard keys, want %d", len(handled), chats)
+	}
+	for key, texts := range handled {
+		if len(texts) != perChat {
+			t.Errorf("%s: handled %d updates, want %d", key, len(texts), perChat)
+		}
+		for i, text := range texts {
+			if text != fmt.Sprint(i) {
+				t.Errorf("%s: update %d is %q, order %v", key, i, text, texts)
+				break
+			}
+		}
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d := dispatch.New(updates.HandlerFunc(func(context.Context, types.Update) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}), dispatch.WithWorkers(1), dispatch.WithQueueSize(1))
+
+	u := types.Update{Chat: types.Chat{Type: types.GroupChatType, ID: "chat"}}
+	ctx := context.Background()
+	if err := d.HandleUpdate(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := d.HandleUpdate(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if d.Pending() != 1 {
+		t.Fatalf("Pending() = %d, want 1", d.Pending())
+	}
+
+	full, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := d.HandleUpdate(full, u); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("HandleUpdate() with a full queue = %v, want DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleUpdate(ctx, u); !errors.Is(err, dispatch.ErrClosed) {
+		t.Fatalf("HandleUpdate() after Shutdown = %v, want ErrClosed", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	const queued = 50
+	var started, dropped, afterShutdown atomic.Int64
+	var shutdownReturned atomic.Bool
+	d := dispatch.New(updates.HandlerFunc(func(ctx context.Context, _ types.Update) error {
+		started.Add(1)
+		if shutdownReturned.Load() {
+			afterShutdown.Add(1)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}), dispatch.WithWorkers(1), dispatch.WithQueueSize(queued), dispatch.WithErrorHandler(func(_ context.Context, _ types.Update, err error) {
+		if errors.Is(err, dispatch.ErrDropped) {
+			dropped.Add(1)
+		}
+	}))
+	for i := 0; i < queued; i++ {
+		if err := d.HandleUpdate(context.Background(), types.Update{From: types.Sender{Login: "user"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := d.Shutdown(ctx)
+	shutdownReturned.Store(true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v, want DeadlineExceeded", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := afterShutdown.Load(); n != 0 {
+		t.Fatalf("%d handlers started after Shutdown returned", n)
+	}
+	if started.Load() != 1 || dropped.Load() != queued-1 {
+		t.Fatalf("started %d handlers, dropped %d updates, want 1 and %d", started.Load(), dropped.Load(), queued-1)
+	}
+}
@@ -0,0 +1,248 @@
+// Package fsm models multi-step dialogs as a state machine with a session per user and chat.
+package fsm
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/keyboard"
+	"github.com/Liriker/YaMa/router"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Idle - state of a user without an active dialog. Sessions in this state are not stored.
+const Idle = ""
+
+// Session - state of the dialog with a user in a chat.
+// Data - values collected during the dialog, e.g. answers to the previous questions. It is never nil in handlers.
+type Session struct {
+	State     string            `json:"state"`
+	Data      map[string]string `json:"data,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	next *string
+}
+
+// Goto - moves the dialog to state instead of the target state of the transition, e.g. to ask again.
+func (s *Session) Goto(state string) {
+	s.next = &state
+}
+
+// Stay - keeps the current state instead of moving to the target state of the transition.
+func (s *Session) Stay() {
+	s.Goto(s.State)
+}
+
+func newSession() *Session {
+	return &Session{State: Idle, Data: map[string]string{}}
+}
+
+func (s *Session) clone() *Session {
+	c := *s
+	c.Data = maps.Clone(s.Data)
+	if c.Data == nil {
+		c.Data = map[string]string{}
+	}
+	c.next = nil
+	return &c
+}
+
+// Trigger - reports whether the update fires a transition.
+type Trigger func(update types.Update) bool
+
+// Any - fires on every update.
+func Any() Trigger {
+	return func(types.Update) bool {
+		return true
+	}
+}
+
+// AnyText - fires on a text message that is not a command or a button click.
+func AnyText() Trigger {
+	return func(update types.Update) bool {
+		_, isCommand := router.Parse(update.Text)
+		return update.Kind() == types.TextUpdate && strings.TrimSpace(update.Text) != "" && !isCommand
+	}
+}
+
+// Text - fires on a message with one of the texts, ignoring case and surrounding spaces.
+func Text(texts ...string) Trigger {
+	return func(update types.Update) bool {
+		text := strings.TrimSpace(update.Text)
+		return slices.ContainsFunc(texts, func(t string) bool {
+			return strings.EqualFold(t, text)
+		})
+	}
+}
+
+// Command - fires on one of the commands, see router.Parse.
+func Command(names ...string) Trigger {
+	return func(update types.Update) bool {
+		cmd, ok := router.Parse(update.Text)
+		return ok && slices.ContainsFunc(names, func(n string) bool {
+			return strings.EqualFold(strings.TrimPrefix(n, "/"), cmd.Name)
+		})
+	}
+}
+
+// Callback - fires on a click on a button of one of the actions, see keyboard.Action.
+func Callback(actions ...string) Trigger {
+	return func(update types.Update) bool {
+		if update.CallbackData == nil {
+			return false
+		}
+		name, err := keyboard.ActionName(update.CallbackData)
+		return err == nil && slices.Contains(actions, name)
+	}
+}
+
+// Handler - action of a transition. Changes of session.Data are saved after it returns without an error.
+// If it returns an error, the state is not changed.
+type Handler func(ctx context.Context, session *Session, update types.Update) error
+
+type transition struct {
+	from    string
+	trigger Trigger
+	to      string
+	handler Handler
+}
+
+// Machine - updates.Handler that runs dialogs.
+// For every update it loads the session of the user in the chat, finds the first transition from the current
+// state whose trigger fires, runs its handler and saves the new state. Updates that fire no transition
+// go to the fallback handler.
+// Sessions idle for longer than the timeout are reset when the next update of the user comes.
+type Machine struct {
+	store       Store
+	transitions []transition
+	timeout     time.Duration
+	cancel      []string
+	onCancel    Handler
+	onTimeout   Handler
+	fallback    updates.Handler
+}
+
+// Option - configures Machine.
+type Option func(*Machine)
+
+// WithTimeout - how long a dialog may wait for the next update. Zero means forever.
+func WithTimeout(d time.Duration, onTimeout Handler) Option {
+	return func(m *Machine) {
+		m.timeout = d
+		m.onTimeout = onTimeout
+	}
+}
+
+// WithCancelCommands - commands that end any active dialog, e.g. "cancel". onCancel may be nil.
+func WithCancelCommands(onCancel Handler, commands ...string) Option {
+	return func(m *Machine) {
+		m.cancel = commands
+		m.onCancel = onCancel
+	}
+}
+
+// WithFallback - handler of updates that fire no transition.
+func WithFallback(h updates.Handler) Option {
+	return func(m *Machine) {
+		m.fallback = h
+	}
+}
+
+func New(store Store, opts ...Option) *Machine {
+	m := &Machine{
+		store: store,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// On - adds a transition from the state to the state to, fired by trigger. handler may be nil.
+// Transition to Idle ends the dialog.
+func (m *Machine) On(from string, trigger Trigger, to string, handler Handler) {
+	m.transitions = append(m.transitions, transition{from: from, trigger: trigger, to: to, handler: handler})
+}
+
+// Key - key of the session of the update sender in its chat.
+func Key(update types.Update) string {
+	sender := update.From.Login
+	if sender == "" {
+		sender = update.From.ID
+	}
+	if update.Chat.Type == types.PrivateChatType || update.Chat.ID == "" {
+		return "private:" + sender
+	}
+	return update.Chat.ID + ":" + sender
+}
+
+// State - current state of the session of the update sender.
+func (m *Machine) State(ctx context.Context, update types.Update) (string, error) {
+	session, err := m.store.Get(ctx, Key(update))
+	if err != nil || session == nil {
+		return Idle, err
+	}
+	return session.State, nil
+}
+
+func (m *Machine) HandleUpdate(ctx context.Context, update types.Update) error {
+	key := Key(update)
+	session, err := m.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		session = newSession()
+	}
+	if session.Data == nil {
+		session.Data = map[string]string{}
+	}
+
+	if session.State != Idle && m.timeout > 0 && time.Since(session.UpdatedAt) > m.timeout {
+		if err := m.end(ctx, key, session, update, m.onTimeout); err != nil {
+			return err
+		}
+		session = newSession()
+	}
+	if session.State != Idle && Command(m.cancel...)(update) {
+		return m.end(ctx, key, session, update, m.onCancel)
+	}
+
+	for _, t := range m.transitions {
+		if t.from != session.State || !t.trigger(update) {
+			continue
+		}
+		if t.handler != nil {
+			if err := t.handler(ctx, session, update); err != nil {
+				return err
+			}
+		}
+		session.State = t.to
+		if session.next != nil {
+			session.State = *session.next
+		}
+		if session.State == Idle {
+			return m.store.Delete(ctx, key)
+		}
+		session.UpdatedAt = time.Now()
+		return m.store.Set(ctx, key, session)
+	}
+
+	if m.fallback != nil {
+		return m.fallback.HandleUpdate(ctx, update)
+	}
+	return nil
+}
+
+func (m *Machine) end(ctx context.Context, key string, session *Session, update types.Update, handler Handler) error {
+	if err := m.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	if handler == nil {
+		return nil
+	}
+	return handler(ctx, session, update)
+}
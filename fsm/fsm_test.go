@@ -0,0 +1,38 @@
+package fsm
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestMachineCollectsData(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(filepath.Join(t.TempDir(), "sessions.json")),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			var created string
+			m := New(store)
+			m.On(Idle, Command("create"), "name", nil)
+			m.On("name", AnyText(), Idle, func(ctx context.Context, s *Session, u types.Update) error {
+				s.Data["name"] = u.Text
+				created = s.Data["name"]
+				return nil
+			})
+
+			ctx := context.Background()
+			for _, text := range []string{"/create", "Team"} {
+				u := types.Update{From: types.Sender{Login: "user"}, Chat: types.Chat{Type: types.PrivateChatType}, Text: text}
+				if err := m.HandleUpdate(ctx, u); err != nil {
+					t.Fatalf("HandleUpdate(%q): %v", text, err)
+				}
+			}
+			if created != "Team" {
+				t.Fatalf("created %q, want %q", created, "Team")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store - storage of sessions by key. Get returns nil without an error if there is no session.
+type Store interface {
+	Get(ctx context.Context, key string) (*Session, error)
+	Set(ctx context.Context, key string, session *Session) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore - Store that keeps sessions in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]Session{},
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	return session.clone(), nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[key] = *session.clone()
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// FileStore - Store that keeps all sessions in one JSON file, so they survive restarts.
+// The file is read once and replaced atomically on every change. It must not be shared between processes.
+type FileStore struct {
+	path     string
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	return session.clone(), nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.sessions[key] = *session.clone()
+	return s.save()
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.sessions[key]; !ok {
+		return nil
+	}
+	delete(s.sessions, key)
+	return s.save()
+}
+
+func (s *FileStore) load() error {
+	if s.sessions != nil {
+		return nil
+	}
+	sessions := map[string]Session{}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &sessions); err != nil {
+			return err
+		}
+	}
+	s.sessions = sessions
+	return nil
+}
+
+func (s *FileStore) save() error {
+	data, err := json.Marshal(s.sessions)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,143 @@
+package keyboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+)
+
+var (
+	// ErrNotAction - callback data was not made by an Action.
+	ErrNotAction = errors.New("keyboard: callback data is not an action")
+	// ErrUnknownAction - no handler is registered for the action of callback data.
+	ErrUnknownAction = errors.New("keyboard: unknown action")
+)
+
+// envelope - callback data of an action button.
+type envelope struct {
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Action - named kind of button click with a payload of type T.
+// The payload is encoded into the callback data of the button as JSON together with the name,
+// so keep it small and use json tags with short names.
+type Action[T any] struct {
+	name string
+}
+
+func NewAction[T any](name string) Action[T] {
+	return Action[T]{name: name}
+}
+
+// Name - name of the action.
+func (a Action[T]) Name() string {
+	return a.name
+}
+
+// Button - button that sends the action with payload when clicked.
+func (a Action[T]) Button(text string, payload T) types.Button {
+	return Button(text, a.Encode(payload))
+}
+
+// Encode - callback data with the action and payload.
+func (a Action[T]) Encode(payload T) interface{} {
+	return struct {
+		Action string `json:"action"`
+		Data   T      `json:"data"`
+	}{a.name, payload}
+}
+
+// Decode - payload of callback data. ok is false if data belongs to another action.
+func (a Action[T]) Decode(data interface{}) (payload T, ok bool, err error) {
+	e, err := decodeEnvelope(data)
+	if err != nil || e.Action != a.name {
+		return payload, false, err
+	}
+	if len(e.Data) > 0 {
+		if err := json.Unmarshal(e.Data, &payload); err != nil {
+			return payload, false, fmt.Errorf("keyboard: decode %s: %w", a.name, err)
+		}
+	}
+	return payload, true, nil
+}
+
+// ActionName - name of the action of callback data.
+func ActionName(data interface{}) (string, error) {
+	e, err := decodeEnvelope(data)
+	return e.Action, err
+}
+
+func decodeEnvelope(data interface{}) (envelope, error) {
+	var e envelope
+	if err := unmarshalData(data, &e); err != nil || e.Action == "" {
+		return envelope{}, ErrNotAction
+	}
+	return e, nil
+}
+
+// Registry - updates.Handler that passes button clicks to the handlers of their actions.
+// Updates that are not clicks on action buttons go to the fallback handler.
+type Registry struct {
+	handlers map[string]func(ctx context.Context, data json.RawMessage, update types.Update) error
+	fallback updates.Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: map[string]func(context.Context, json.RawMessage, types.Update) error{},
+	}
+}
+
+// Handle - registers handler of clicks on the buttons of action. Registering the same action again replaces the handler.
+func Handle[T any](r *Registry, action Action[T], handler func(ctx context.Context, payload T, update types.Update) error) {
+	r.handlers[action.name] = func(ctx context.Context, data json.RawMessage, update types.Update) error {
+		var payload T
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return fmt.Errorf("keyboard: decode %s: %w", action.name, err)
+			}
+		}
+		return handler(ctx, payload, update)
+	}
+}
+
+// Dispatch - passes the click with callback data to the handler of its action.
+func (r *Registry) Dispatch(ctx context.Context, data interface{}, update types.Update) error {
+	e, err := decodeEnvelope(data)
+	if err != nil {
+		return err
+	}
+	handler, ok := r.handlers[e.Action]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAction, e.Action)
+	}
+	return handler(ctx, e.Data, update)
+}
+
+// Default - handler of updates without callback data and of clicks on buttons of unknown actions.
+// By default the clicks fail with ErrUnknownAction or ErrNotAction and other updates are ignored.
+func (r *Registry) Default(handler updates.Handler) {
+	r.fallback = handler
+}
+
+func (r *Registry) HandleUpdate(ctx context.Context, update types.Update) error {
+	if update.CallbackData == nil {
+		return r.serveDefault(ctx, update, nil)
+	}
+	err := r.Dispatch(ctx, update.CallbackData, update)
+	if errors.Is(err, ErrUnknownAction) || errors.Is(err, ErrNotAction) {
+		return r.serveDefault(ctx, update, err)
+	}
+	return err
+}
+
+func (r *Registry) serveDefault(ctx context.Context, update types.Update, err error) error {
+	if r.fallback == nil {
+		return err
+	}
+	return r.fallback.HandleUpdate(ctx, update)
+}
@@ -0,0 +1,138 @@
+// Package keyboard builds inline keyboards and encodes typed callback data of their buttons.
+package keyboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Liriker/YaMa/types"
+	"unicode/utf8"
+)
+
+// ErrInvalidKeyboard - the keyboard does not fit the limits. Errors of Build wrap it.
+var ErrInvalidKeyboard = errors.New("keyboard: invalid keyboard")
+
+// Limits - size limits of a keyboard. Zero values are not checked.
+// MaxButtons - number of buttons under a message.
+// MaxRowButtons - number of buttons in a row.
+// MaxTextLength - length of the button text in characters.
+// MaxCallbackDataSize - size of the JSON-encoded callback data of a button in bytes.
+type Limits struct {
+	MaxButtons          int
+	MaxRowButtons       int
+	MaxTextLength       int
+	MaxCallbackDataSize int
+}
+
+// DefaultLimits - limits Keyboard checks by default.
+var DefaultLimits = Limits{
+	MaxButtons:          100,
+	MaxRowButtons:       8,
+	MaxTextLength:       64,
+	MaxCallbackDataSize: 1024,
+}
+
+// Button - button with the text and callback data sent to the bot when it is clicked.
+func Button(text string, data interface{}) types.Button {
+	return types.Button{
+		Text:         text,
+		CallbackData: data,
+	}
+}
+
+// TextButton - button without callback data.
+func TextButton(text string) types.Button {
+	return types.Button{Text: text}
+}
+
+// Keyboard - builder of an inline keyboard. The API takes a flat list of buttons, rows keep their order
+// and are checked against Limits.MaxRowButtons.
+type Keyboard struct {
+	rows   [][]types.Button
+	limits Limits
+	signer *Signer
+}
+
+func New() *Keyboard {
+	return &Keyboard{
+		limits: DefaultLimits,
+	}
+}
+
+// WithLimits - replaces the limits checked by Build.
+func (k *Keyboard) WithLimits(limits Limits) *Keyboard {
+	k.limits = limits
+	return k
+}
+
+// WithSigner - signs callback data of all buttons with signer in Build.
+func (k *Keyboard) WithSigner(signer *Signer) *Keyboard {
+	k.signer = signer
+	return k
+}
+
+// Row - adds a new row with the buttons.
+func (k *Keyboard) Row(buttons ...types.Button) *Keyboard {
+	k.rows = append(k.rows, buttons)
+	return k
+}
+
+// Add - adds the buttons to the last row.
+func (k *Keyboard) Add(buttons ...types.Button) *Keyboard {
+	if len(k.rows) == 0 {
+		return k.Row(buttons...)
+	}
+	k.rows[len(k.rows)-1] = append(k.rows[len(k.rows)-1], buttons...)
+	return k
+}
+
+// Rows - rows added so far.
+func (k *Keyboard) Rows() [][]types.Button {
+	return k.rows
+}
+
+// Build - checks the keyboard and returns its buttons for types.NewMessage.InlineKeyboard.
+func (k *Keyboard) Build() ([]types.Button, error) {
+	var buttons []types.Button
+	for i, row := range k.rows {
+		if k.limits.MaxRowButtons > 0 && len(row) > k.limits.MaxRowButtons {
+			return nil, fmt.Errorf("%w: row %d has %d buttons, max %d", ErrInvalidKeyboard, i, len(row), k.limits.MaxRowButtons)
+		}
+		for _, b := range row {
+			if k.signer != nil {
+				var err error
+				if b, err = k.signer.Button(b); err != nil {
+					return nil, fmt.Errorf("%w: callback data of button %q: %v", ErrInvalidKeyboard, b.Text, err)
+				}
+			}
+			if err := k.limits.check(b); err != nil {
+				return nil, err
+			}
+			buttons = append(buttons, b)
+		}
+	}
+	if k.limits.MaxButtons > 0 && len(buttons) > k.limits.MaxButtons {
+		return nil, fmt.Errorf("%w: %d buttons, max %d", ErrInvalidKeyboard, len(buttons), k.limits.MaxButtons)
+	}
+	return buttons, nil
+}
+
+func (l Limits) check(b types.Button) error {
+	if b.Text == "" {
+		return fmt.Errorf("%w: button without text", ErrInvalidKeyboard)
+	}
+	if n := utf8.RuneCountInString(b.Text); l.MaxTextLength > 0 && n > l.MaxTextLength {
+		return fmt.Errorf("%w: text of button %q has %d characters, max %d", ErrInvalidKeyboard, b.Text, n, l.MaxTextLength)
+	}
+	if b.CallbackData == nil {
+		return nil
+	}
+	data, err := json.Marshal(b.CallbackData)
+	if err != nil {
+		return fmt.Errorf("%w: callback data of button %q: %v", ErrInvalidKeyboard, b.Text, err)
+	}
+	if l.MaxCallbackDataSize > 0 && len(data) > l.MaxCallbackDataSize {
+		return fmt.Errorf("%w: callback data of button %q has %d bytes, max %d", ErrInvalidKeyboard, b.Text, len(data), l.MaxCallbackDataSize)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package keyboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBadSignature - callback data is not signed or its signature does not match.
+	ErrBadSignature = errors.New("keyboard: bad callback data signature")
+	// ErrExpired - signed callback data has expired.
+	ErrExpired = errors.New("keyboard: callback data expired")
+	// ErrWeakSecret - the secret of a Signer is shorter than MinSecretSize.
+	ErrWeakSecret = errors.New("keyboard: signer secret is too short")
+)
+
+// MinSecretSize - minimum size of the Signer secret in bytes, the size of the HMAC-SHA256 output.
+const MinSecretSize = 32
+
+// signed - callback data of a signed button.
+// Token - "<payload>.<expiry>.<mac>": base64url JSON of the original data, UNIX expiry time (0 - never)
+// and base64url HMAC-SHA256 of the first two parts.
+type signed struct {
+	Token string `json:"signed"`
+}
+
+// Signer - signs callback data with HMAC and an expiry time, so that clicks with payloads crafted
+// or kept by the client are rejected.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSigner - signer with the secret key of at least MinSecretSize random bytes.
+// Signed data expires in ttl with a resolution of a second, zero ttl means never.
+// Bot instances that handle each other's clicks must share the secret.
+func NewSigner(secret []byte, ttl time.Duration) (*Signer, error) {
+	if len(secret) < MinSecretSize {
+		return nil, fmt.Errorf("%w: %d bytes, min %d", ErrWeakSecret, len(secret), MinSecretSize)
+	}
+	return &Signer{
+		secret: bytes.Clone(secret),
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+// Sign - signed callback data that carries data.
+func (s *Signer) Sign(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var expires int64
+	if s.ttl > 0 {
+		expires = s.now().Add(s.ttl).Unix()
+	}
+	msg := base64.RawURLEncoding.EncodeToString(raw) + "." + strconv.FormatInt(expires, 10)
+	return signed{Token: msg + "." + base64.RawURLEncoding.EncodeToString(s.mac(msg))}, nil
+}
+
+// Button - button with the callback data of b signed. Buttons without callback data are returned as is.
+func (s *Signer) Button(b types.Button) (types.Button, error) {
+	if b.CallbackData == nil {
+		return b, nil
+	}
+	data, err := s.Sign(b.CallbackData)
+	if err != nil {
+		return b, err
+	}
+	b.CallbackData = data
+	return b, nil
+}
+
+// Verify - checks signed callback data and returns the original data as JSON.
+func (s *Signer) Verify(data interface{}) (json.RawMessage, error) {
+	var sd signed
+	if err := unmarshalData(data, &sd); err != nil || sd.Token == "" {
+		return nil, ErrBadSignature
+	}
+	dot := strings.LastIndexByte(sd.Token, '.')
+	if dot < 0 {
+		return nil, ErrBadSignature
+	}
+	msg := sd.Token[:dot]
+	mac, err := base64.RawURLEncoding.DecodeString(sd.Token[dot+1:])
+	if err != nil || !hmac.Equal(mac, s.mac(msg)) {
+		return nil, ErrBadSignature
+	}
+
+	payload, exp, ok := strings.Cut(msg, ".")
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	if expires > 0 && s.now().Unix() > expires {
+		return nil, ErrExpired
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	return raw, nil
+}
+
+// Middleware - verifies callback data of button clicks and replaces it with the original data.
+// Clicks with unsigned, tampered or expired data are dropped, onReject is called for them, if it is not nil.
+// Other updates pass as is.
+func (s *Signer) Middleware(onReject func(ctx context.Context, update types.Update, err error)) updates.Middleware {
+	return func(next updates.Handler) updates.Handler {
+		return updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+			if update.Kind() != types.CallbackUpdate {
+				return next.HandleUpdate(ctx, update)
+			}
+			data, err := s.Verify(update.CallbackData)
+			if err != nil {
+				if onReject != nil {
+					onReject(ctx, update, err)
+				}
+				return nil
+			}
+			update.CallbackData = data
+			return next.HandleUpdate(ctx, update)
+		})
+	}
+}
+
+func (s *Signer) mac(msg string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// unmarshalData decodes callback data in any form: as built by the package, decoded from JSON into interface{}
+// or as raw JSON.
+func unmarshalData(data interface{}, v interface{}) error {
+	var raw []byte
+	switch d := data.(type) {
+	case json.RawMessage:
+		raw = d
+	case []byte:
+		raw = d
+	default:
+		var err error
+		if raw, err = json.Marshal(d); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("keyboard: decode callback data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package keyboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = bytes.Repeat([]byte("k"), MinSecretSize)
+
+func TestNewSignerRejectsWeakSecret(t *testing.T) {
+	for _, secret := range [][]byte{nil, {}, []byte("short")} {
+		if _, err := NewSigner(secret, time.Minute); !errors.Is(err, ErrWeakSecret) {
+			t.Errorf("NewSigner(%q) = %v, want ErrWeakSecret", secret, err)
+		}
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	s, err := NewSigner(testSecret, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	data, err := s.Sign(NewAction[int]("approve").Encode(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(data)
+	var received interface{}
+	if err := json.Unmarshal(raw, &received); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Verify(received)
+	if err != nil || string(got) != `{"action":"approve","data":7}` {
+		t.Fatalf("Verify() = %s, %v", got, err)
+	}
+
+	tampered := map[string]interface{}{"signed": "x" + received.(map[string]interface{})["signed"].(string)}
+	if _, err := s.Verify(tampered); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(tampered) = %v, want ErrBadSignature", err)
+	}
+	other, _ := NewSigner(bytes.Repeat([]byte("o"), MinSecretSize), time.Minute)
+	if _, err := other.Verify(received); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify with another secret = %v, want ErrBadSignature", err)
+	}
+	if _, err := s.Verify(map[string]interface{}{"action": "approve", "data": 7}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify(unsigned) = %v, want ErrBadSignature", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := s.Verify(received); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify(expired) = %v, want ErrExpired", err)
+	}
+	if !strings.Contains(string(raw), `"signed"`) {
+		t.Errorf("signed data %s", raw)
+	}
+}
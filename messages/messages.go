@@ -2,9 +2,11 @@ package messages
 
 import (
 	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/Liriker/YaMa/api"
 	"github.com/Liriker/YaMa/types"
 	"io"
 	"mime/multipart"
@@ -14,12 +16,12 @@ import (
 )
 
 const (
-	sendMessageUrl   = "https://botapi.messenger.yandex.net/bot/v1/messages/sendText/"
-	sendFileUrl      = "https://botapi.messenger.yandex.net/bot/v1/messages/sendFile/"
-	getFileUrl       = "https://botapi.messenger.yandex.net/bot/v1/messages/getFile/"
-	sendImageUrl     = "https://botapi.messenger.yandex.net/bot/v1/messages/sendImage/"
-	sendGalleryUrl   = "https://botapi.messenger.yandex.net/bot/v1/messages/sendGallery/"
-	deleteMessageUrl = "https://botapi.messenger.yandex.net/bot/v1/messages/delete"
+	sendMessagePath   = "messages/sendText/"
+	sendFilePath      = "messages/sendFile/"
+	getFilePath       = "messages/getFile/"
+	sendImagePath     = "messages/sendImage/"
+	sendGalleryPath   = "messages/sendGallery/"
+	deleteMessagePath = "messages/delete"
 
 	documentFiledName = "Document"
 	imageFieldName    = "Image"
@@ -27,242 +29,152 @@ const (
 )
 
 type Client struct {
-	client  *http.Client
-	headers http.Header
+	executor *api.Executor
 }
 
-func NewClient(cl *http.Client, h http.Header) *Client {
+func NewClient(e *api.Executor) *Client {
 	return &Client{
-		client:  cl,
-		headers: h,
+		executor: e,
 	}
 }
 
 func (cl *Client) Send(message types.NewMessage) (int64, error) {
-	data, err := json.Marshal(message)
-	if err != nil {
-		return 0, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, sendMessageUrl, bytes.NewBuffer(data))
-	if err != nil {
-		return 0, err
-	}
-	req.Header = cl.headers
-
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
+	return cl.SendCtx(context.Background(), message)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, errors.New(fmt.Sprint(resp))
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
+// SendCtx - same as Send, the request is bound to ctx (cancellation, deadline).
+// Empty PayloadID of the message is filled with a random one, so the server drops duplicates of retried requests.
+func (cl *Client) SendCtx(ctx context.Context, message types.NewMessage) (int64, error) {
+	if message.PayloadID == "" {
+		id, err := newPayloadID()
+		if err != nil {
+			return 0, err
+		}
+		message.PayloadID = id
 	}
-	result := response{}
-	err = json.Unmarshal(body, &result)
+	req, err := api.NewJSONRequest(http.MethodPost, sendMessagePath, message)
 	if err != nil {
 		return 0, err
 	}
-	if !result.Ok {
-		return 0, errors.New(result.Description)
-	}
-	return result.MessageID, nil
+	req.LimitKey = api.ChatKey(message.ChatID, message.Login)
+	return cl.send(ctx, req)
 }
 
 func (cl *Client) SendFile(message types.NewFileMessage, filename string) (int64, error) {
-	data, err := structToMultipartForm(message, filename)
-	if err != nil {
-		return 0, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, sendFileUrl, data)
-	if err != nil {
-		return 0, err
-	}
-	headers := cl.headers.Clone()
-	headers.Set("Content-Disposition", "document; filename=\""+filename+"\"")
-	headers.Set("Content-Type", "multipart/form-data; boundary="+boundary)
-	req.Header = headers
+	return cl.SendFileCtx(context.Background(), message, filename)
+}
 
-	resp, err := cl.client.Do(req)
+// SendFileCtx - same as SendFile, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) SendFileCtx(ctx context.Context, message types.NewFileMessage, filename string) (int64, error) {
+	req, err := multipartRequest(sendFilePath, message, filename)
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return 0, err
-		}
-		return 0, errors.New(string(data))
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, errors.New(fmt.Sprint(resp))
-	}
-
-	result := response{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return 0, errors.New(fmt.Sprint(resp))
-	}
-	if !result.Ok {
-		return 0, errors.New(result.Description)
-	}
-	return result.MessageID, nil
+	req.Header.Set("Content-Disposition", "document; filename=\""+filename+"\"")
+	req.LimitKey = api.ChatKey(message.ChatID, message.Login)
+	return cl.send(ctx, req)
 }
 
+// GetFile - downloads the file with id. The contents are read into memory before returning.
 func (cl *Client) GetFile(id int64) (io.ReadCloser, error) {
+	return cl.GetFileCtx(context.Background(), id)
+}
+
+// GetFileCtx - same as GetFile, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) GetFileCtx(ctx context.Context, id int64) (io.ReadCloser, error) {
 	js := getFileRequest{FileID: id}
-	data, err := json.Marshal(&js.FileID)
+	req, err := api.NewJSONRequest(http.MethodGet, getFilePath, &js.FileID)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequest(http.MethodGet, getFileUrl, bytes.NewBuffer(data))
+	req.Retry = true
+	data, err := cl.executor.Download(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	req.Header = cl.headers
-
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprint(resp))
-	}
-	return resp.Body, nil
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
 func (cl *Client) SendImage(message types.NewImageMessage, filename string) (int64, error) {
-	data, err := structToMultipartForm(message, filename)
-	if err != nil {
-		return 0, err
-	}
-	req, err := http.NewRequest(http.MethodPost, sendImageUrl, data)
-	if err != nil {
-		return 0, err
-	}
-
-	headers := cl.headers.Clone()
-	headers.Set("Content-Type", "multipart/form-data; boundary="+boundary)
-	req.Header = headers
-
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, errors.New(string(body))
-	}
+	return cl.SendImageCtx(context.Background(), message, filename)
+}
 
+// SendImageCtx - same as SendImage, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) SendImageCtx(ctx context.Context, message types.NewImageMessage, filename string) (int64, error) {
+	req, err := multipartRequest(sendImagePath, message, filename)
 	if err != nil {
 		return 0, err
 	}
-	result := response{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return 0, err
-	}
-	if !result.Ok {
-		return 0, errors.New(result.Description)
-	}
-	return result.MessageID, nil
+	req.LimitKey = api.ChatKey(message.ChatID, message.Login)
+	return cl.send(ctx, req)
 }
 
 func (cl *Client) SendGallery(message types.NewGalleryMessage, filenames ...string) (int64, error) {
-	data, err := structToMultipartForm(message, filenames...)
-	if err != nil {
-		return 0, err
-	}
+	return cl.SendGalleryCtx(context.Background(), message, filenames...)
+}
 
-	req, err := http.NewRequest(http.MethodPost, sendGalleryUrl, data)
+// SendGalleryCtx - same as SendGallery, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) SendGalleryCtx(ctx context.Context, message types.NewGalleryMessage, filenames ...string) (int64, error) {
+	req, err := multipartRequest(sendGalleryPath, message, filenames...)
 	if err != nil {
 		return 0, err
 	}
+	req.LimitKey = api.ChatKey(message.ChatID, message.Login)
+	return cl.send(ctx, req)
+}
 
-	headers := cl.headers.Clone()
-	headers.Set("Content-Type", "multipart/form-data; boundary="+boundary)
-	req.Header = headers
+func (cl *Client) Delete(request types.NewDeleteMessageRequest) (int64, error) {
+	return cl.DeleteCtx(context.Background(), request)
+}
 
-	resp, err := cl.client.Do(req)
+// DeleteCtx - same as Delete, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) DeleteCtx(ctx context.Context, request types.NewDeleteMessageRequest) (int64, error) {
+	req, err := api.NewJSONRequest(http.MethodPost, deleteMessagePath, request)
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
+	req.LimitKey = api.ChatKey(request.ChatID, request.Login)
+	return cl.send(ctx, req)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return 0, errors.New(string(body))
-	}
-	if err != nil {
-		return 0, err
-	}
+// send - executes a retryable message request and returns the message ID from the response.
+func (cl *Client) send(ctx context.Context, req *api.Request) (int64, error) {
+	req.Retry = true
 	result := response{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
+	if err := cl.executor.Do(ctx, req, &result); err != nil {
 		return 0, err
 	}
-	if !result.Ok {
-		return 0, errors.New(result.Description)
-	}
 	return result.MessageID, nil
 }
 
-func (cl *Client) Delete(request types.NewDeleteMessageRequest) (int64, error) {
-	data, err := json.Marshal(request)
-	if err != nil {
-		return 0, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, deleteMessageUrl, bytes.NewBuffer(data))
-	if err != nil {
-		return 0, err
-	}
-	req.Header = cl.headers
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return 0, err
+func newPayloadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
+	return hex.EncodeToString(b), nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func multipartRequest(endpoint string, value any, filenames ...string) (*api.Request, error) {
+	data, err := structToMultipartForm(value, filenames...)
 	if err != nil {
-		return 0, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return 0, errors.New(string(body))
-	}
-	result := response{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return 0, err
-	}
-	if !result.Ok {
-		return 0, errors.New(result.Description)
+		return nil, err
 	}
-	return result.MessageID, nil
-
+	return &api.Request{
+		Method:   http.MethodPost,
+		Endpoint: endpoint,
+		Header:   http.Header{"Content-Type": {"multipart/form-data; boundary=" + boundary}},
+		Body:     data,
+	}, nil
 }
 
-func structToMultipartForm(value any, filenames ...string) (io.Reader, error) {
+func structToMultipartForm(value any, filenames ...string) ([]byte, error) {
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
 	err := w.SetBoundary(boundary)
 	if err != nil {
 		return nil, err
 	}
-	defer w.Close()
 	structure := reflect.ValueOf(value)
 	valueTypes := structure.Type()
 	for i := 0; i < structure.NumField(); i++ {
@@ -306,5 +218,8 @@ func structToMultipartForm(value any, filenames ...string) (io.Reader, error) {
 		}
 
 	}
-	return &b, nil
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
 }
@@ -0,0 +1,151 @@
+// Package middleware provides updates.Middleware for common concerns of bots:
+// panic recovery, access control, flood throttling, logging and skipping of updates from bots.
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/Liriker/YaMa/api"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// PanicError - error made of a panic recovered in a handler.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in update handler: %v", e.Value)
+}
+
+// Recover - turns panics of the next handler into *PanicError and reports them to report, if it is not nil.
+func Recover(report func(ctx context.Context, update types.Update, err error)) updates.Middleware {
+	return func(next updates.Handler) updates.Handler {
+		return updates.HandlerFunc(func(ctx context.Context, update types.Update) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+					if report != nil {
+						report(ctx, update, err)
+					}
+				}
+			}()
+			return next.HandleUpdate(ctx, update)
+		})
+	}
+}
+
+// Filter - passes to the next handler only updates for which allow returns true. Other updates are dropped.
+func Filter(allow func(ctx context.Context, update types.Update) bool) updates.Middleware {
+	return func(next updates.Handler) updates.Handler {
+		return updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+			if !allow(ctx, update) {
+				return nil
+			}
+			return next.HandleUpdate(ctx, update)
+		})
+	}
+}
+
+// AllowLogins - passes only updates from the senders with the logins.
+func AllowLogins(logins ...string) updates.Middleware {
+	return Filter(func(_ context.Context, update types.Update) bool {
+		return slices.Contains(logins, update.From.Login)
+	})
+}
+
+// DenyLogins - drops updates from the senders with the logins.
+func DenyLogins(logins ...string) updates.Middleware {
+	return Filter(func(_ context.Context, update types.Update) bool {
+		return !slices.Contains(logins, update.From.Login)
+	})
+}
+
+// OrganizationResolver - returns IDs of the organizations the sender belongs to.
+// Updates don't carry organizations, so they are resolved by the bot, e.g. from a directory or a cache.
+type OrganizationResolver func(ctx context.Context, sender types.Sender) ([]int64, error)
+
+// AllowOrganizations - passes only updates from the members of the organizations.
+// Updates whose sender can't be resolved are dropped.
+func AllowOrganizations(resolve OrganizationResolver, organizations ...int64) updates.Middleware {
+	return Filter(func(ctx context.Context, update types.Update) bool {
+		orgs, err := resolve(ctx, update.From)
+		return err == nil && intersects(orgs, organizations)
+	})
+}
+
+// DenyOrganizations - drops updates from the members of the organizations.
+// Updates whose sender can't be resolved are dropped too.
+func DenyOrganizations(resolve OrganizationResolver, organizations ...int64) updates.Middleware {
+	return Filter(func(ctx context.Context, update types.Update) bool {
+		orgs, err := resolve(ctx, update.From)
+		return err == nil && !intersects(orgs, organizations)
+	})
+}
+
+// SkipRobots - drops updates sent by bots.
+func SkipRobots() updates.Middleware {
+	return Filter(func(_ context.Context, update types.Update) bool {
+		return !update.From.Robot
+	})
+}
+
+// Throttle - drops updates of senders that send more than rate allows. onDrop is called for dropped updates, if it is not nil.
+func Throttle(rate api.Rate, onDrop func(ctx context.Context, update types.Update)) updates.Middleware {
+	limiter := api.NewRateLimiter(api.Rate{}, rate)
+	return Filter(func(ctx context.Context, update types.Update) bool {
+		if limiter.Allow(senderKey(update.From)) {
+			return true
+		}
+		if onDrop != nil {
+			onDrop(ctx, update)
+		}
+		return false
+	})
+}
+
+// Logger - logs every update with the result and duration of its handling.
+func Logger(logger *slog.Logger) updates.Middleware {
+	return func(next updates.Handler) updates.Handler {
+		return updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+			start := time.Now()
+			err := next.HandleUpdate(ctx, update)
+			attrs := []slog.Attr{
+				slog.Int64("update_id", update.UpdateID),
+				slog.String("chat_type", update.Chat.Type),
+				slog.String("chat_id", update.Chat.ID),
+				slog.String("from", senderKey(update.From)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+				logger.LogAttrs(ctx, slog.LevelError, "update failed", attrs...)
+				return err
+			}
+			logger.LogAttrs(ctx, slog.LevelInfo, "update handled", attrs...)
+			return nil
+		})
+	}
+}
+
+func senderKey(sender types.Sender) string {
+	if sender.Login != "" {
+		return sender.Login
+	}
+	return sender.ID
+}
+
+func intersects(a, b []int64) bool {
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
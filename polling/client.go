@@ -1,15 +1,261 @@
 package polling
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/api"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"sync/atomic"
+	"time"
+)
 
+const (
+	defaultLimit       = 100
+	defaultIdleTimeout = time.Second
+	defaultMaxIdle     = 5 * time.Second
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = 30 * time.Second
+)
+
+// Client - long-polling loop over updates.Client.GetUpdates.
+// The client remembers the offset of the next update, so Run and Start can be called again after a stop
+// and continue from the same place. Run and Start must not be used concurrently.
 type Client struct {
-	client  *http.Client
-	headers http.Header
+	updates     *updates.Client
+	limit       int64
+	idleTimeout time.Duration
+	maxIdle     time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	store       OffsetStore
+	commitMode  CommitMode
+	offset      atomic.Int64
+	err         atomic.Pointer[error]
+	unacked     atomic.Int64
+	onError     func(ctx context.Context, err error)
+}
+
+// Option - configures Client.
+type Option func(*Client)
+
+// WithLimit - maximum number of updates requested by one getUpdates call.
+func WithLimit(limit int64) Option {
+	return func(c *Client) {
+		c.limit = limit
+	}
+}
+
+// WithIdleTimeout - fixed delay before the next getUpdates call when the previous one returned no updates.
+func WithIdleTimeout(d time.Duration) Option {
+	return WithIdleBackoff(d, d)
+}
+
+// WithIdleBackoff - bounds of the delay before the next getUpdates call when the previous one returned no updates.
+// The delay doubles after every empty batch up to max and is reset to min by a batch with updates.
+// Default is from 1 to 5 seconds.
+func WithIdleBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.idleTimeout = min
+		c.maxIdle = max
+	}
+}
+
+// WithBackoff - bounds of the exponential delay between getUpdates calls that failed.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithErrorHandler - called for every failed getUpdates call that is going to be repeated.
+func WithErrorHandler(f func(ctx context.Context, err error)) Option {
+	return func(c *Client) {
+		c.onError = f
+	}
+}
+
+// WithOffset - offset of the first update to request.
+func WithOffset(offset int64) Option {
+	return func(c *Client) {
+		c.offset.Store(offset)
+	}
+}
+
+func NewClient(u *updates.Client, opts ...Option) *Client {
+	c := &Client{
+		updates:     u,
+		limit:       defaultLimit,
+		idleTimeout: defaultIdleTimeout,
+		maxIdle:     defaultMaxIdle,
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Offset - offset of the next update that will be requested.
+func (c *Client) Offset() int64 {
+	return c.offset.Load()
+}
+
+// Run - polls updates and passes them to handler one by one until ctx is cancelled.
+// Failed getUpdates calls are repeated with exponential backoff and reported to the error handler,
+// except api.ErrUnauthorized and api.ErrForbidden, which do not go away on retry: Run stops and returns them.
+// If an OffsetStore is set, the offset is loaded from it first and saved after every batch (AtLeastOnce)
+// or before handling the batch (AtMostOnce).
+// In AtLeastOnce mode the offset is advanced only after the handler has returned successfully, so if handler returns
+// an error Run stops and returns that error, and the failed update will be requested again on the next Run.
+// In AtMostOnce mode the whole batch is committed in advance and a failed update is not requested again.
+// Run returns nil when ctx is cancelled.
+func (c *Client) Run(ctx context.Context, handler updates.Handler) error {
+	if c.store != nil {
+		offset, err := c.store.Load(ctx)
+		if err != nil {
+			return err
+		}
+		if offset > c.offset.Load() {
+			c.offset.Store(offset)
+		}
+	}
+
+	backoff, idle := c.minBackoff, c.idleTimeout
+	for ctx.Err() == nil {
+		batch, next, err := c.updates.GetUpdatesCtx(ctx, c.limit, c.offset.Load())
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if errors.Is(err, api.ErrUnauthorized) || errors.Is(err, api.ErrForbidden) {
+				return err
+			}
+			if c.onError != nil {
+				c.onError(ctx, err)
+			}
+			if !sleep(ctx, backoff) {
+				break
+			}
+			backoff = min(backoff*2, c.maxBackoff)
+			continue
+		}
+		backoff = c.minBackoff
+
+		if len(batch) == 0 {
+			if !sleep(ctx, idle) {
+				break
+			}
+			idle = max(min(idle*2, c.maxIdle), c.idleTimeout)
+			continue
+		}
+		idle = c.idleTimeout
+
+		if c.commitMode == AtMostOnce {
+			c.offset.Store(next)
+			if err := c.commit(ctx); err != nil {
+				return err
+			}
+		}
+		err = c.handle(ctx, handler, batch)
+		if c.commitMode == AtLeastOnce {
+			if err == nil && ctx.Err() == nil {
+				c.offset.Store(next)
+			}
+			if commitErr := c.commit(context.WithoutCancel(ctx)); commitErr != nil && err == nil {
+				err = commitErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) handle(ctx context.Context, handler updates.Handler, batch []types.Update) error {
+	for _, update := range batch {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := handler.HandleUpdate(ctx, update); err != nil {
+			return err
+		}
+		if c.commitMode == AtLeastOnce {
+			c.offset.Store(update.UpdateID + 1)
+		}
+	}
+	return nil
+}
+
+// commit - saves the current offset to the store, if there is one.
+func (c *Client) commit(ctx context.Context) error {
+	if c.store == nil {
+		return nil
+	}
+	offset := c.offset.Load()
+	if id := c.unacked.Load(); id > 0 && c.commitMode == AtLeastOnce {
+		offset = min(offset, id)
+	}
+	return c.store.Save(ctx, offset)
+}
+
+// Start - runs the polling loop in a new goroutine and delivers updates over the returned channel.
+// The channel is closed after ctx is cancelled.
+// The consumer acknowledges an update by receiving the next one, so in AtLeastOnce mode the offset saved to
+// the OffsetStore never passes the last received update: it is requested again after a restart.
+// Consumers that receive the next update before the previous one is handled, e.g. handle them in other goroutines,
+// get the guarantee only for the receiving, not for the handling.
+// updates.Serve does not use the channel: it passes the handler to Run, so the offset is committed exactly
+// after every handled update and a restart does not replay it.
+func (c *Client) Start(ctx context.Context) <-chan types.Update {
+	ch := make(chan types.Update)
+	c.err.Store(nil)
+	c.unacked.Store(0)
+	go func() {
+		defer close(ch)
+		err := c.Run(ctx, updates.HandlerFunc(func(ctx context.Context, update types.Update) error {
+			select {
+			case ch <- update:
+				// After cancellation the receive may come from a consumer that is stopping, e.g. updates.Serve
+				// after a failed handler, so it does not acknowledge the previous update.
+				if ctx.Err() == nil || c.unacked.Load() == 0 {
+					c.unacked.Store(update.UpdateID)
+				}
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}))
+		if err != nil && ctx.Err() == nil {
+			c.err.Store(&err)
+		}
+	}()
+	return ch
+}
+
+// Updates - same as Start, makes Client an updates.Source.
+func (c *Client) Updates(ctx context.Context) <-chan types.Update {
+	return c.Start(ctx)
+}
+
+// Err - error that stopped the loop started with Start, nil if it was stopped by ctx.
+func (c *Client) Err() error {
+	if err := c.err.Load(); err != nil {
+		return *err
+	}
+	return nil
 }
 
-func NewClient(cl *http.Client, h http.Header) *Client {
-	return &Client{
-		client:  cl,
-		headers: h,
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
@@ -0,0 +1,152 @@
+package polling_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/api"
+	"github.com/Liriker/YaMa/polling"
+	"github.com/Liriker/YaMa/transport"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"github.com/Liriker/YaMa/yamatest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunReturnsUnauthorized(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	client := transport.NewClient("bad", transport.WithBaseURL(srv.URL()), transport.WithRetryPolicy(api.NoRetry()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p := polling.NewClient(client.Updates, polling.WithBackoff(time.Millisecond, time.Millisecond))
+	err := p.Run(ctx, updates.HandlerFunc(func(context.Context, types.Update) error { return nil }))
+	if !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("Run() = %v, want ErrUnauthorized", err)
+	}
+
+	for range p.Start(ctx) {
+	}
+	if !errors.Is(p.Err(), api.ErrUnauthorized) {
+		t.Fatalf("Err() = %v, want ErrUnauthorized", p.Err())
+	}
+}
+
+func TestRunReportsTransientErrors(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	srv.AddFault(yamatest.ServerErrors("messages/getUpdates/", 2, http.StatusServiceUnavailable))
+	srv.InjectText("user", "hello")
+	client := srv.NewClient(transport.WithRetryPolicy(api.NoRetry()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var failures int
+	p := polling.NewClient(client.Updates,
+		polling.WithBackoff(time.Millisecond, time.Millisecond),
+		polling.WithErrorHandler(func(context.Context, error) { failures++ }),
+	)
+	err := p.Run(ctx, updates.HandlerFunc(func(context.Context, types.Update) error {
+		cancel()
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if failures != 2 {
+		t.Fatalf("error handler called %d times, want 2", failures)
+	}
+}
+
+func TestServeCommitsOnlyHandledUpdates(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	for _, text := range []string{"one", "two", "three"} {
+		srv.InjectText("user", text)
+	}
+	client := srv.NewClient()
+
+	store := &polling.MemoryOffsetStore{}
+	p := polling.NewClient(client.Updates, polling.WithOffsetStore(store), polling.WithIdleTimeout(time.Millisecond))
+	failed := errors.New("failed")
+	err := updates.Serve(context.Background(), p, updates.HandlerFunc(func(_ context.Context, u types.Update) error {
+		if u.Text == "two" {
+			return failed
+		}
+		return nil
+	}))
+	if !errors.Is(err, failed) {
+		t.Fatalf("Serve() = %v, want %v", err, failed)
+	}
+	if offset, _ := store.Load(context.Background()); offset != 2 {
+		t.Fatalf("saved offset %d, want 2 (the failed update)", offset)
+	}
+}
+
+func TestServeRestartDoesNotReplay(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	client := srv.NewClient()
+	store := &polling.MemoryOffsetStore{}
+
+	serve := func() []string {
+		var texts []string
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		p := polling.NewClient(client.Updates, polling.WithOffsetStore(store), polling.WithIdleTimeout(time.Millisecond))
+		err := updates.Serve(ctx, p, updates.HandlerFunc(func(_ context.Context, u types.Update) error {
+			texts = append(texts, u.Text)
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("Serve() = %v", err)
+		}
+		return texts
+	}
+
+	for _, text := range []string{"one", "two", "three"} {
+		srv.InjectText("user", text)
+	}
+	if texts := serve(); len(texts) != 3 {
+		t.Fatalf("first run handled %v, want 3 updates", texts)
+	}
+	if offset, _ := store.Load(context.Background()); offset != 4 {
+		t.Fatalf("saved offset %d, want 4", offset)
+	}
+	srv.InjectText("user", "four")
+	if texts := serve(); len(texts) != 1 || texts[0] != "four" {
+		t.Fatalf("second run handled %v, want [four]", texts)
+	}
+}
+
+func TestRunIdleBackoff(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+	client := srv.NewClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handled := make(chan struct{})
+	p := polling.NewClient(client.Updates, polling.WithIdleBackoff(10*time.Millisecond, 160*time.Millisecond))
+	go p.Run(ctx, updates.HandlerFunc(func(context.Context, types.Update) error {
+		handled <- struct{}{}
+		return nil
+	}))
+
+	// Delays 10, 20, 40, 80, 160, 160 ms: about 6 calls in 500 ms, a fixed 10 ms delay would make 50.
+	time.Sleep(500 * time.Millisecond)
+	if calls := srv.Calls("messages/getUpdates/"); calls > 10 {
+		t.Fatalf("%d getUpdates calls while idle, want the delay to grow", calls)
+	}
+
+	srv.InjectText("user", "hello")
+	<-handled
+	// The batch resets the delay to 10 ms, with the 160 ms delay there would be at most one call.
+	before := srv.Calls("messages/getUpdates/")
+	time.Sleep(100 * time.Millisecond)
+	if calls := srv.Calls("messages/getUpdates/") - before; calls < 3 {
+		t.Fatalf("%d getUpdates calls after a batch, want the delay to be reset", calls)
+	}
+}
@@ -0,0 +1,118 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// OffsetStore - storage of the offset of the next update, so a restarted bot continues where it stopped.
+type OffsetStore interface {
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, offset int64) error
+}
+
+// CommitMode - when the offset is saved relative to handling of updates.
+type CommitMode int
+
+const (
+	// AtLeastOnce - the offset is saved after the updates are handled, with Start - received, see Client.Start.
+	// After a crash some updates may be handled again.
+	AtLeastOnce CommitMode = iota
+	// AtMostOnce - the offset is saved before the updates are handled. After a crash some updates may be lost.
+	AtMostOnce
+)
+
+// WithOffsetStore - store of the offset. The offset is loaded when Run starts and saved according to the commit mode.
+func WithOffsetStore(store OffsetStore) Option {
+	return func(c *Client) {
+		c.store = store
+	}
+}
+
+// WithCommitMode - when the offset is saved to the store. Default is AtLeastOnce.
+func WithCommitMode(mode CommitMode) Option {
+	return func(c *Client) {
+		c.commitMode = mode
+	}
+}
+
+// MemoryOffsetStore - OffsetStore that keeps the offset in memory, e.g. to share it between clients in one process.
+type MemoryOffsetStore struct {
+	offset atomic.Int64
+}
+
+func (s *MemoryOffsetStore) Load(context.Context) (int64, error) {
+	return s.offset.Load(), nil
+}
+
+func (s *MemoryOffsetStore) Save(_ context.Context, offset int64) error {
+	s.offset.Store(offset)
+	return nil
+}
+
+// FileOffsetStore - OffsetStore that keeps the offset in a file.
+// The file is replaced atomically: the offset is written to a temporary file, synced and renamed.
+type FileOffsetStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileOffsetStore(path string) *FileOffsetStore {
+	return &FileOffsetStore{path: path}
+}
+
+// Load - reads the offset. A missing file means offset 0.
+func (s *FileOffsetStore) Load(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (s *FileOffsetStore) Save(_ context.Context, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(offset, 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Command - command parsed from the text of an update.
+// Name - command name in lower case without the slash and the mention, e.g. "help" for "/Help@bot foo".
+// Mention - bot name after "@" in the command, empty if there is none.
+// Args - arguments split by spaces. Quotes "..." and '...' group words, backslash escapes the next character.
+// RawArgs - text after the command as is.
+type Command struct {
+	Name    string
+	Mention string
+	Args    []string
+	RawArgs string
+}
+
+// Parse - parses text starting with "/". A leading mention, e.g. "@bot /start", is treated as "/start@bot".
+func Parse(text string) (Command, bool) {
+	text = strings.TrimSpace(text)
+	mention := ""
+	if strings.HasPrefix(text, "@") {
+		if i := strings.IndexFunc(text, unicode.IsSpace); i > 0 {
+			mention = text[1:i]
+			text = strings.TrimSpace(text[i:])
+		}
+	}
+	if !strings.HasPrefix(text, "/") || len(text) == 1 {
+		return Command{}, false
+	}
+
+	head, rest := text[1:], ""
+	if i := strings.IndexFunc(head, unicode.IsSpace); i >= 0 {
+		head, rest = head[:i], strings.TrimSpace(head[i:])
+	}
+	cmd := Command{RawArgs: rest}
+	cmd.Name, cmd.Mention, _ = strings.Cut(head, "@")
+	cmd.Name = strings.ToLower(cmd.Name)
+	if cmd.Mention == "" {
+		cmd.Mention = mention
+	}
+	if cmd.Name == "" {
+		return Command{}, false
+	}
+	cmd.Args = SplitArgs(rest)
+	return cmd, true
+}
+
+// SplitArgs - splits s into arguments like a shell does: by spaces, with "..." and '...' quoting and backslash escapes.
+// An unterminated quote lasts until the end of s.
+func SplitArgs(s string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+type commandKey struct{}
+
+// WithCommand - returns ctx with the command, as the router passes it to handlers.
+func WithCommand(ctx context.Context, cmd Command) context.Context {
+	return context.WithValue(ctx, commandKey{}, cmd)
+}
+
+// CommandFromContext - command of the update being handled by a command handler.
+func CommandFromContext(ctx context.Context) (Command, bool) {
+	cmd, ok := ctx.Value(commandKey{}).(Command)
+	return cmd, ok
+}
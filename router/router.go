@@ -0,0 +1,139 @@
+// Package router routes text updates with commands like "/help foo bar" to handlers.
+package router
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"strings"
+)
+
+type route struct {
+	name        string
+	description string
+	handler     updates.Handler
+}
+
+// Router - updates.Handler that passes updates with commands to the handlers registered for them.
+// Handlers get the parsed command with CommandFromContext.
+// Updates with unregistered commands go to the unknown-command handler, other updates go to the default handler.
+// Commands addressed to another bot in group chats ("/start@otherbot") are ignored.
+// Button clicks go to the callback handler and are never parsed as commands.
+type Router struct {
+	routes   map[string]*route
+	order    []*route
+	names    []string
+	unknown  updates.Handler
+	fallback updates.Handler
+	callback updates.Handler
+}
+
+// Option - configures Router.
+type Option func(*Router)
+
+// WithBotNames - names the bot is mentioned by, e.g. its login. Commands mentioning other names are ignored.
+// Without it any mention is accepted.
+func WithBotNames(names ...string) Option {
+	return func(r *Router) {
+		for _, n := range names {
+			r.names = append(r.names, strings.ToLower(strings.TrimPrefix(n, "@")))
+		}
+	}
+}
+
+func New(opts ...Option) *Router {
+	r := &Router{
+		routes: map[string]*route{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Handle - registers handler for the command name, with or without the slash. description is shown by Help.
+// Registering the same name again replaces the handler.
+func (r *Router) Handle(name, description string, handler updates.Handler) {
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if rt, ok := r.routes[name]; ok {
+		rt.description = description
+		rt.handler = handler
+		return
+	}
+	rt := &route{name: name, description: description, handler: handler}
+	r.routes[name] = rt
+	r.order = append(r.order, rt)
+}
+
+// HandleFunc - same as Handle for a function.
+func (r *Router) HandleFunc(name, description string, f func(ctx context.Context, update types.Update) error) {
+	r.Handle(name, description, updates.HandlerFunc(f))
+}
+
+// Unknown - handler of commands that are not registered. By default they are ignored.
+func (r *Router) Unknown(handler updates.Handler) {
+	r.unknown = handler
+}
+
+// Default - handler of updates without a command. By default they are ignored.
+func (r *Router) Default(handler updates.Handler) {
+	r.fallback = handler
+}
+
+// Callback - handler of button clicks, updates of kind types.CallbackUpdate, e.g. keyboard.Registry.
+// By default they are ignored.
+func (r *Router) Callback(handler updates.Handler) {
+	r.callback = handler
+}
+
+// Help - list of registered commands with descriptions, one per line, in the order of registration.
+func (r *Router) Help() string {
+	var b strings.Builder
+	for _, rt := range r.order {
+		b.WriteString("/" + rt.name)
+		if rt.description != "" {
+			b.WriteString(" - " + rt.description)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (r *Router) HandleUpdate(ctx context.Context, update types.Update) error {
+	if update.Kind() == types.CallbackUpdate {
+		return r.serve(ctx, r.callback, update)
+	}
+	cmd, ok := Parse(update.Text)
+	if !ok {
+		return r.serve(ctx, r.fallback, update)
+	}
+	if cmd.Mention != "" && !r.isBot(cmd.Mention) {
+		return nil
+	}
+
+	ctx = WithCommand(ctx, cmd)
+	if rt, ok := r.routes[cmd.Name]; ok {
+		return rt.handler.HandleUpdate(ctx, update)
+	}
+	return r.serve(ctx, r.unknown, update)
+}
+
+func (r *Router) isBot(mention string) bool {
+	if len(r.names) == 0 {
+		return true
+	}
+	mention = strings.ToLower(mention)
+	for _, n := range r.names {
+		if n == mention {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Router) serve(ctx context.Context, handler updates.Handler, update types.Update) error {
+	if handler == nil {
+		return nil
+	}
+	return handler.HandleUpdate(ctx, update)
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Command
+		ok   bool
+	}{
+		{"/start", Command{Name: "start"}, true},
+		{"  /Help  ", Command{Name: "help"}, true},
+		{"/help@Bot foo", Command{Name: "help", Mention: "Bot", Args: []string{"foo"}, RawArgs: "foo"}, true},
+		{"@bot /start now", Command{Name: "start", Mention: "bot", Args: []string{"now"}, RawArgs: "now"}, true},
+		{"@bot /start@other", Command{Name: "start", Mention: "other"}, true},
+		{"@bot hello", Command{}, false},
+		{"/deploy api  \"two words\" 'x y'", Command{Name: "deploy", Args: []string{"api", "two words", "x y"}, RawArgs: "api  \"two words\" 'x y'"}, true},
+		{`/say a\ b c\"d`, Command{Name: "say", Args: []string{"a b", `c"d`}, RawArgs: `a\ b c\"d`}, true},
+		{`/say 'a\b' "a\"b"`, Command{Name: "say", Args: []string{`a\b`, `a"b`}, RawArgs: `'a\b' "a\"b"`}, true},
+		{`/say "open quote`, Command{Name: "say", Args: []string{"open quote"}, RawArgs: `"open quote`}, true},
+		{`/say "" x`, Command{Name: "say", Args: []string{"", "x"}, RawArgs: `"" x`}, true},
+		{"/", Command{}, false},
+		{"/@bot", Command{}, false},
+		{"hello /start", Command{}, false},
+		{"", Command{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.text)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, %v, want %#v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRouterHandleUpdate(t *testing.T) {
+	var got []string
+	record := func(name string) func(context.Context, types.Update) error {
+		return func(ctx context.Context, u types.Update) error {
+			entry := name
+			if cmd, ok := CommandFromContext(ctx); ok {
+				entry += ":" + cmd.Name
+			}
+			got = append(got, entry)
+			return nil
+		}
+	}
+	r := New(WithBotNames("@MyBot"))
+	r.HandleFunc("start", "starts", record("start"))
+	r.Unknown(updates.HandlerFunc(record("unknown")))
+	r.Default(updates.HandlerFunc(record("default")))
+	r.Callback(updates.HandlerFunc(record("callback")))
+
+	for _, u := range []types.Update{
+		{Text: "/start"},
+		{Text: "/START@mybot"},
+		{Text: "@mybot /start"},
+		{Text: "/start@otherbot"},
+		{Text: "@otherbot /start"},
+		{Text: "/stop"},
+		{Text: "/stop@otherbot"},
+		{Text: "hello"},
+		{Text: "/start", CallbackData: map[string]interface{}{"action": "x"}},
+	} {
+		if err := r.HandleUpdate(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"start:start", "start:start", "start:start", "unknown:stop", "default", "callback"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("handled %v, want %v", got, want)
+	}
+	if help := r.Help(); help != "/start - starts" {
+		t.Fatalf("Help() = %q", help)
+	}
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"github.com/Liriker/YaMa/api"
 	"github.com/Liriker/YaMa/chats"
 	"github.com/Liriker/YaMa/messages"
 	"github.com/Liriker/YaMa/polling"
@@ -8,27 +9,38 @@ import (
 	"net/http"
 )
 
+// Client - Bot API client with sub-clients for every group of methods.
+// Client and its sub-clients are safe for concurrent use by multiple goroutines, except for Polling,
+// whose loop must be run by one goroutine at a time.
+// The token and other options are fixed when the client is created.
 type Client struct {
-	httpClient *http.Client
-	headers    http.Header
-	Chats      *chats.Client
-	Messages   *messages.Client
-	Polling    *polling.Client
-	Updates    *updates.Client
+	executor *api.Executor
+	Chats    *chats.Client
+	Messages *messages.Client
+	Polling  *polling.Client
+	Updates  *updates.Client
 }
 
-func NewClient(token string) *Client {
-	client := http.DefaultClient
+func NewClient(token string, opts ...Option) *Client {
+	cfg := newConfig(opts)
 	headers := http.Header{}
 	headers.Add("Authorization", "OAuth "+token)
-	headers.Add("Content-Type", "application/json")
+	if cfg.userAgent != "" {
+		headers.Set("User-Agent", cfg.userAgent)
+	}
+	executor := api.NewExecutor(cfg.client(), cfg.baseURL, headers, cfg.retry, cfg.limiter, cfg.middlewares...)
 
+	upd := updates.NewClient(executor)
 	return &Client{
-		httpClient: client,
-		headers:    headers,
-		Chats:      chats.NewClient(client, headers),
-		Messages:   messages.NewClient(client, headers),
-		Polling:    polling.NewClient(client, headers),
-		Updates:    updates.NewClient(client, headers),
+		executor: executor,
+		Chats:    chats.NewClient(executor),
+		Messages: messages.NewClient(executor),
+		Polling:  polling.NewClient(upd),
+		Updates:  upd,
 	}
 }
+
+// BaseURL - base URL of the Bot API used by the client.
+func (c *Client) BaseURL() string {
+	return c.executor.BaseURL()
+}
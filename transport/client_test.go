@@ -0,0 +1,84 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Liriker/YaMa/api"
+	"github.com/Liriker/YaMa/transport"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/yamatest"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// checkHeaders - fails the request if the X-Text header set by the middleware does not match the text in the body.
+type checkHeaders struct {
+	next http.RoundTripper
+}
+
+func (c checkHeaders) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var msg types.NewMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	if got := r.Header.Get("X-Text"); got != msg.Text {
+		return nil, fmt.Errorf("X-Text %q of message %q", got, msg.Text)
+	}
+	return c.next.RoundTrip(r)
+}
+
+// TestParallelSend - run with -race: concurrent sends through a middleware that changes headers must not share them.
+func TestParallelSend(t *testing.T) {
+	srv := yamatest.NewServer("token")
+	defer srv.Close()
+
+	setText := func(next api.Handler) api.Handler {
+		return func(ctx context.Context, req *api.Request) (*api.Response, error) {
+			var msg types.NewMessage
+			if err := json.Unmarshal(req.Body, &msg); err != nil {
+				return nil, err
+			}
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			req.Header.Set("X-Text", msg.Text)
+			return next(ctx, req)
+		}
+	}
+	client := srv.NewClient(
+		transport.WithMiddleware(setText),
+		transport.WithTransport(checkHeaders{next: http.DefaultTransport}),
+		transport.WithRetryPolicy(api.NoRetry()),
+	)
+
+	const senders, messages = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, senders*messages)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				text := fmt.Sprintf("message %d/%d", i, j)
+				if _, err := client.Messages.Send(types.NewMessage{Login: "user", Text: text}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	srv.AssertSentCount(t, senders*messages)
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"github.com/Liriker/YaMa/api"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL - base URL of Yandex Messenger Bot API.
+const DefaultBaseURL = "https://botapi.messenger.yandex.net/bot/v1/"
+
+type config struct {
+	httpClient   *http.Client
+	roundTripper http.RoundTripper
+	baseURL      string
+	timeout      time.Duration
+	proxy        *url.URL
+	userAgent    string
+	retry        api.RetryPolicy
+	limiter      *api.RateLimiter
+	middlewares  []api.Middleware
+}
+
+// Option - configures Client created by NewClient.
+type Option func(*config)
+
+// WithHTTPClient - http.Client used for all requests. The client is copied, so later changes of cl do not affect Client.
+func WithHTTPClient(cl *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = cl
+	}
+}
+
+// WithTransport - RoundTripper used for all requests instead of the transport of the http.Client.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *config) {
+		c.roundTripper = rt
+	}
+}
+
+// WithBaseURL - base URL of the Bot API, e.g. address of a local stand-in server in tests. Default is DefaultBaseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithTimeout - default timeout of every request, see http.Client.Timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithProxy - proxy for all requests.
+// The proxy is set on a copy of the *http.Transport in use, so it has no effect with a RoundTripper of another type.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *config) {
+		c.proxy = proxy
+	}
+}
+
+// WithUserAgent - value of the User-Agent header of every request.
+func WithUserAgent(ua string) Option {
+	return func(c *config) {
+		c.userAgent = ua
+	}
+}
+
+// WithRetryPolicy - policy of repeating failed message requests. Default is api.DefaultRetryPolicy, use api.NoRetry to disable retries.
+func WithRetryPolicy(p api.RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = p
+	}
+}
+
+// WithRateLimiter - limiter shared by all sub-clients. Keep a reference to it to read its statistics.
+func WithRateLimiter(l *api.RateLimiter) Option {
+	return func(c *config) {
+		c.limiter = l
+	}
+}
+
+// WithRateLimit - creates a limiter with the global and per-chat (per-login for private chats) rates, see WithRateLimiter.
+func WithRateLimit(global, perChat api.Rate) Option {
+	return WithRateLimiter(api.NewRateLimiter(global, perChat))
+}
+
+// WithMiddleware - adds middlewares to the request pipeline shared by all sub-clients.
+// The first middleware is the outermost one. Every retry attempt passes the middlewares again.
+func WithMiddleware(mws ...api.Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, mws...)
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		baseURL: DefaultBaseURL,
+		retry:   api.DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if !strings.HasSuffix(cfg.baseURL, "/") {
+		cfg.baseURL += "/"
+	}
+	return cfg
+}
+
+func (cfg *config) client() *http.Client {
+	client := &http.Client{}
+	if cfg.httpClient != nil {
+		*client = *cfg.httpClient
+	}
+	if cfg.roundTripper != nil {
+		client.Transport = cfg.roundTripper
+	}
+	if cfg.timeout > 0 {
+		client.Timeout = cfg.timeout
+	}
+	if cfg.proxy != nil {
+		rt := client.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		if t, ok := rt.(*http.Transport); ok {
+			t = t.Clone()
+			t.Proxy = http.ProxyURL(cfg.proxy)
+			client.Transport = t
+		}
+	}
+	return client
+}
@@ -15,15 +15,81 @@ const (
 // UpdateID - update ID.
 // File - Information about the file attached to the message.
 // Images - Information about the pictures.
+// ThreadID - ID of the thread the message was sent to (timestamp of the thread's first message), zero outside threads.
+// CallbackData - callback data of the inline button the user clicked. JSON objects are decoded into map[string]interface{}.
+// BotRequest - request of an interactive element of the message, e.g. a submitted form.
 type Update struct {
-	From      Sender    `json:"from"`
-	Chat      Chat      `json:"chat"`
-	Text      string    `json:"text,omitempty"`
-	Timestamp int64     `json:"timestamp"`
-	MessageID int64     `json:"message_id"`
-	UpdateID  int64     `json:"update_id"`
-	File      File      `json:"file,omitempty"`
-	Images    [][]Image `json:"images,omitempty"`
+	From         Sender      `json:"from"`
+	Chat         Chat        `json:"chat"`
+	Text         string      `json:"text,omitempty"`
+	Timestamp    int64       `json:"timestamp"`
+	MessageID    int64       `json:"message_id"`
+	UpdateID     int64       `json:"update_id"`
+	File         File        `json:"file,omitempty"`
+	Images       [][]Image   `json:"images,omitempty"`
+	ThreadID     int64       `json:"thread_id,omitempty"`
+	CallbackData interface{} `json:"callback_data,omitempty"`
+	BotRequest   *BotRequest `json:"bot_request,omitempty"`
+}
+
+// UpdateKind - kind of the update, see Update.Kind.
+type UpdateKind string
+
+const (
+	UnknownUpdate    UpdateKind = "unknown"
+	TextUpdate       UpdateKind = "text"
+	FileUpdate       UpdateKind = "file"
+	ImageUpdate      UpdateKind = "image"
+	CallbackUpdate   UpdateKind = "callback"
+	BotRequestUpdate UpdateKind = "bot_request"
+)
+
+// Kind - what the update carries. A click on a button is CallbackUpdate even if the update also has the text of the button.
+func (u Update) Kind() UpdateKind {
+	switch {
+	case u.CallbackData != nil:
+		return CallbackUpdate
+	case u.BotRequest != nil:
+		return BotRequestUpdate
+	case u.File.ID != "":
+		return FileUpdate
+	case len(u.Images) > 0:
+		return ImageUpdate
+	case u.Text != "":
+		return TextUpdate
+	default:
+		return UnknownUpdate
+	}
+}
+
+// BotRequest - It is used in responses to describe a request of an interactive element to the bot.
+// ServerAction - action the element asks the bot to perform.
+// ElementID - ID of the element that sent the request.
+type BotRequest struct {
+	ServerAction *ServerAction `json:"server_action,omitempty"`
+	ElementID    string        `json:"element_id,omitempty"`
+}
+
+// ServerAction - It is used in responses to describe an action requested by an interactive element.
+// Name - name of the action.
+// Payload - data of the action.
+type ServerAction struct {
+	Name    string      `json:"name"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// BotInfo - It is used in responses to describe the bot itself.
+// ID - bot ID.
+// DisplayName - bot's display name.
+// Login - bot's login.
+// Organizations - IDs of the organizations the bot belongs to.
+// WebhookUrl - current webhook URL. Empty if updates are received with getUpdates.
+type BotInfo struct {
+	ID            string  `json:"id"`
+	DisplayName   string  `json:"display_name"`
+	Login         string  `json:"login"`
+	Organizations []int64 `json:"organizations"`
+	WebhookUrl    string  `json:"webhook_url,omitempty"`
 }
 
 // Button - it is used in queries to describe an inline button under a text message.
@@ -1,102 +1,103 @@
 package updates
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
+	"github.com/Liriker/YaMa/api"
 	"github.com/Liriker/YaMa/types"
-	"io"
 	"net/http"
 )
 
 const (
-	updateUrl = "https://botapi.messenger.yandex.net/bot/v1/messages/getUpdates/"
+	updatePath     = "messages/getUpdates/"
+	selfPath       = "self/get/"
+	selfUpdatePath = "self/update/"
 )
 
 type Client struct {
-	client  *http.Client
-	headers http.Header
+	executor *api.Executor
 }
 
-func NewClient(cl *http.Client, h http.Header) *Client {
+func NewClient(e *api.Executor) *Client {
 	return &Client{
-		client:  cl,
-		headers: h,
+		executor: e,
 	}
 }
 
 func (cl *Client) GetUpdates(limit, offset int64) ([]types.Update, int64, error) {
+	return cl.GetUpdatesCtx(context.Background(), limit, offset)
+}
+
+// GetUpdatesCtx - same as GetUpdates, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) GetUpdatesCtx(ctx context.Context, limit, offset int64) ([]types.Update, int64, error) {
 	reqBody := updateRequest{
 		Limit:  limit,
 		Offset: offset,
 	}
-	data, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, 0, err
-	}
-	req, err := http.NewRequest(http.MethodPost, updateUrl, bytes.NewBuffer(data))
-	if err != nil {
-		return nil, 0, err
-	}
-	req.Header = cl.headers
-
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	req, err := api.NewJSONRequest(http.MethodPost, updatePath, reqBody)
 	if err != nil {
 		return nil, 0, err
 	}
-
 	response := updateResponse{}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		fmt.Println(err)
-		return nil, 0, errors.New(string(body))
-	}
-
-	if !response.Ok {
-		return nil, 0, errors.New(response.Description)
+	if err := cl.executor.Do(ctx, req, &response); err != nil {
+		return nil, 0, err
 	}
 	if len(response.Updates) > 0 {
 		offset = response.Updates[len(response.Updates)-1].UpdateID + 1
 	}
 	return response.Updates, offset, nil
+}
 
+// SetWebhook - sets the URL to which the Bot API sends updates. Returns the bot information with the new webhook URL.
+// While the webhook is set, GetUpdates does not return updates.
+func (cl *Client) SetWebhook(url string) (*types.BotInfo, error) {
+	return cl.SetWebhookCtx(context.Background(), url)
 }
 
-func (cl *Client) SetWebhook(url string) (bool, string, error) {
-	reqBody := webhookRequest{
-		WebhookUrl: url,
-	}
-	data, err := json.Marshal(reqBody)
-	if err != nil {
-		return false, "", err
-	}
-	req, err := http.NewRequest(http.MethodPost, updateUrl, bytes.NewBuffer(data))
-	if err != nil {
-		return false, "", err
+// SetWebhookCtx - same as SetWebhook, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) SetWebhookCtx(ctx context.Context, url string) (*types.BotInfo, error) {
+	return cl.updateSelf(ctx, webhookRequest{WebhookUrl: &url})
+}
+
+// DeleteWebhook - removes the webhook, so updates can be received with GetUpdates again.
+func (cl *Client) DeleteWebhook() (*types.BotInfo, error) {
+	return cl.DeleteWebhookCtx(context.Background())
+}
+
+// DeleteWebhookCtx - same as DeleteWebhook, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) DeleteWebhookCtx(ctx context.Context) (*types.BotInfo, error) {
+	return cl.updateSelf(ctx, webhookRequest{})
+}
+
+// GetSelf - information about the bot, including the current webhook URL.
+func (cl *Client) GetSelf() (*types.BotInfo, error) {
+	return cl.GetSelfCtx(context.Background())
+}
+
+// GetSelfCtx - same as GetSelf, the request is bound to ctx (cancellation, deadline).
+func (cl *Client) GetSelfCtx(ctx context.Context) (*types.BotInfo, error) {
+	req := &api.Request{
+		Method:     http.MethodGet,
+		Endpoint:   selfPath,
+		Retry:      true,
+		OkOptional: true,
 	}
-	req.Header = cl.headers
-	resp, err := cl.client.Do(req)
-	if err != nil {
-		return false, "", err
+	info := types.BotInfo{}
+	if err := cl.executor.Do(ctx, req, &info); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &info, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (cl *Client) updateSelf(ctx context.Context, body webhookRequest) (*types.BotInfo, error) {
+	req, err := api.NewJSONRequest(http.MethodPost, selfUpdatePath, body)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
-
-	response := webhookResponse{}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return false, "", errors.New(string(body))
+	req.Retry = true
+	req.OkOptional = true
+	info := types.BotInfo{}
+	if err := cl.executor.Do(ctx, req, &info); err != nil {
+		return nil, err
 	}
-	return response.Ok, response.Id, nil
+	return &info, nil
 }
@@ -0,0 +1,30 @@
+package updates
+
+import (
+	"context"
+	"github.com/Liriker/YaMa/types"
+)
+
+// Handler - handles a single update received from the Bot API.
+// Returning an error tells the caller that the update was not processed.
+type Handler interface {
+	HandleUpdate(ctx context.Context, update types.Update) error
+}
+
+// HandlerFunc - adapter to use ordinary functions as Handler.
+type HandlerFunc func(ctx context.Context, update types.Update) error
+
+func (f HandlerFunc) HandleUpdate(ctx context.Context, update types.Update) error {
+	return f(ctx, update)
+}
+
+// Middleware - wraps Handler to add cross-cutting behaviour such as recovery, logging or filtering.
+type Middleware func(next Handler) Handler
+
+// Chain - wraps handler with middlewares. The first middleware is the outermost one.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,139 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"sync"
+)
+
+// Source - stream of incoming updates independent of the delivery method: polling, webhook or memory for tests.
+// Updates starts the delivery, the channel is closed when ctx is done or the source fails.
+// Err returns the error that stopped the source after the channel is closed, nil if it was stopped by ctx.
+type Source interface {
+	Updates(ctx context.Context) <-chan types.Update
+	Err() error
+}
+
+// Runner - Source that can pass updates to a handler itself and so knows when each of them is handled,
+// e.g. polling.Client, which commits the offset only after that.
+type Runner interface {
+	Source
+	Run(ctx context.Context, handler Handler) error
+}
+
+// Serve - passes updates from src to handler one by one until the source is stopped.
+// If handler returns an error, the source is stopped and the error is returned.
+// If src is a Runner, the handler is run with its Run.
+func Serve(ctx context.Context, src Source, handler Handler) error {
+	if r, ok := src.(Runner); ok {
+		return r.Run(ctx, handler)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var handlerErr error
+	for update := range src.Updates(ctx) {
+		if handlerErr != nil {
+			continue
+		}
+		if err := handler.HandleUpdate(ctx, update); err != nil {
+			handlerErr = err
+			cancel()
+		}
+	}
+	if handlerErr != nil {
+		return handlerErr
+	}
+	return src.Err()
+}
+
+// ErrSourceClosed - returned by MemorySource.Push after Close.
+var ErrSourceClosed = errors.New("update source is closed")
+
+// MemorySource - Source fed by Push, meant for tests of handlers.
+type MemorySource struct {
+	ch     chan types.Update
+	done   chan struct{}
+	pushes sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	err    error
+}
+
+// NewMemorySource - creates MemorySource that buffers up to buffer pushed updates.
+func NewMemorySource(buffer int) *MemorySource {
+	return &MemorySource{
+		ch:   make(chan types.Update, buffer),
+		done: make(chan struct{}),
+	}
+}
+
+// Push - sends updates to the consumer, blocking while the buffer is full.
+// A blocked Push returns ErrSourceClosed when the source is closed.
+func (s *MemorySource) Push(ctx context.Context, updates ...types.Update) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrSourceClosed
+	}
+	s.pushes.Add(1)
+	s.mu.RUnlock()
+	defer s.pushes.Done()
+
+	for _, u := range updates {
+		select {
+		case s.ch <- u:
+		case <-s.done:
+			return ErrSourceClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close - ends the stream after the buffered updates. err is returned by Err.
+func (s *MemorySource) Close(err error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.done)
+	s.mu.Unlock()
+
+	s.pushes.Wait()
+	close(s.ch)
+}
+
+func (s *MemorySource) Updates(ctx context.Context) <-chan types.Update {
+	out := make(chan types.Update)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case u, ok := <-s.ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- u:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (s *MemorySource) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
@@ -0,0 +1,52 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"testing"
+	"time"
+)
+
+func TestMemorySourceCloseUnblocksPush(t *testing.T) {
+	src := NewMemorySource(0)
+	pushed := make(chan error)
+	go func() {
+		pushed <- src.Push(context.Background(), types.Update{UpdateID: 1})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		src.Close(nil)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by Push")
+	}
+	if err := <-pushed; !errors.Is(err, ErrSourceClosed) {
+		t.Fatalf("Push() = %v, want ErrSourceClosed", err)
+	}
+	if err := src.Push(context.Background(), types.Update{UpdateID: 2}); !errors.Is(err, ErrSourceClosed) {
+		t.Fatalf("Push() after Close = %v, want ErrSourceClosed", err)
+	}
+}
+
+func TestMemorySourceDeliversBufferedAfterClose(t *testing.T) {
+	src := NewMemorySource(2)
+	if err := src.Push(context.Background(), types.Update{UpdateID: 1}, types.Update{UpdateID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	src.Close(nil)
+
+	var ids []int64
+	err := Serve(context.Background(), src, HandlerFunc(func(_ context.Context, u types.Update) error {
+		ids = append(ids, u.UpdateID)
+		return nil
+	}))
+	if err != nil || len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("Serve() = %v, handled %v, want [1 2]", err, ids)
+	}
+}
@@ -13,15 +13,7 @@ type updateResponse struct {
 	Description string         `json:"description,omitempty"`
 }
 
+// webhookRequest - body of self/update/. Nil WebhookUrl is sent as null and deletes the webhook.
 type webhookRequest struct {
-	WebhookUrl string `json:"webhook_url"`
-}
-
-type webhookResponse struct {
-	Ok            bool   `json:"ok"`
-	Id            string `json:"id"`
-	DisplayName   string `json:"display_name"`
-	WebhookUrl    string `json:"webhook_url,omitempty"`
-	Organizations []int  `json:"organizations"`
-	Login         string `json:"login"`
+	WebhookUrl *string `json:"webhook_url"`
 }
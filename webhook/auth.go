@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// Stats - counters of webhook requests.
+// Accepted - requests whose updates were all handled.
+// RejectedSource - requests from addresses outside the allowlist.
+// RejectedSecret - requests without the valid secret token.
+// RejectedRequest - requests with wrong method, content type, size or body.
+// HandlerErrors - requests failed by the update handler.
+type Stats struct {
+	Accepted        int64
+	RejectedSource  int64
+	RejectedSecret  int64
+	RejectedRequest int64
+	HandlerErrors   int64
+}
+
+type counters struct {
+	accepted        atomic.Int64
+	rejectedSource  atomic.Int64
+	rejectedSecret  atomic.Int64
+	rejectedRequest atomic.Int64
+	handlerErrors   atomic.Int64
+}
+
+// WithSecretPath - requests must have secret as the last segment of the URL path,
+// e.g. the webhook is set to "https://example.com/yama/<secret>". Other requests get 404.
+func WithSecretPath(secret string) Option {
+	return func(h *Handler) {
+		h.secretPath = secret
+	}
+}
+
+// WithSecretHeader - requests must have the header with the secret value. Other requests get 401.
+func WithSecretHeader(name, secret string) Option {
+	return func(h *Handler) {
+		h.secretHeader = name
+		h.secretHeaderValue = secret
+	}
+}
+
+// WithAllowedNetworks - requests must come from one of the networks. Other requests get 403.
+func WithAllowedNetworks(networks ...netip.Prefix) Option {
+	return func(h *Handler) {
+		h.allowed = append(h.allowed, networks...)
+	}
+}
+
+// WithTrustedProxies - networks of reverse proxies in front of the bot.
+// For requests from them the source address is taken from X-Forwarded-For:
+// the rightmost address that is not a trusted proxy.
+func WithTrustedProxies(networks ...netip.Prefix) Option {
+	return func(h *Handler) {
+		h.proxies = append(h.proxies, networks...)
+	}
+}
+
+// Stats - counters of requests received by the handler.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Accepted:        h.counters.accepted.Load(),
+		RejectedSource:  h.counters.rejectedSource.Load(),
+		RejectedSecret:  h.counters.rejectedSecret.Load(),
+		RejectedRequest: h.counters.rejectedRequest.Load(),
+		HandlerErrors:   h.counters.handlerErrors.Load(),
+	}
+}
+
+func (h *Handler) checkSecret(r *http.Request) (int, bool) {
+	if h.secretPath != "" {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		segment := path[strings.LastIndex(path, "/")+1:]
+		if !secureEqual(segment, h.secretPath) {
+			return http.StatusNotFound, false
+		}
+	}
+	if h.secretHeader != "" && !secureEqual(r.Header.Get(h.secretHeader), h.secretHeaderValue) {
+		return http.StatusUnauthorized, false
+	}
+	return 0, true
+}
+
+func (h *Handler) checkSource(r *http.Request) bool {
+	if len(h.allowed) == 0 {
+		return true
+	}
+	addr, ok := h.source(r)
+	return ok && contains(h.allowed, addr)
+}
+
+// source - address of the client, see WithTrustedProxies.
+func (h *Handler) source(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if !contains(h.proxies, addr) {
+		return addr, true
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		hop = hop.Unmap()
+		if !contains(h.proxies, hop) {
+			return hop, true
+		}
+	}
+	return addr, true
+}
+
+func contains(networks []netip.Prefix, addr netip.Addr) bool {
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
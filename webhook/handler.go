@@ -0,0 +1,117 @@
+// Package webhook receives updates sent by the Bot API to the webhook URL set with updates.Client.SetWebhook.
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"mime"
+	"net/http"
+	"net/netip"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+// payload - body of the webhook request, the same envelope as the getUpdates response.
+type payload struct {
+	Updates []types.Update `json:"updates"`
+}
+
+// Handler - http.Handler that decodes webhook requests and passes every update to the update handler.
+// It can be mounted on any net/http mux.
+// Responses: 200 - all updates are handled; 403 - source address is not allowed; 404 or 401 - no valid secret token;
+// 405 - method is not POST; 415 - body is not JSON; 413 - body is larger than the limit;
+// 400 - body can't be decoded; 500 - the update handler returned an error.
+type Handler struct {
+	handler           updates.Handler
+	maxBodySize       int64
+	onError           func(r *http.Request, err error)
+	secretPath        string
+	secretHeader      string
+	secretHeaderValue string
+	allowed           []netip.Prefix
+	proxies           []netip.Prefix
+	counters          counters
+}
+
+// Option - configures Handler.
+type Option func(*Handler)
+
+// WithMaxBodySize - maximum size of the request body in bytes. Default is 1 MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithErrorHandler - function called for every rejected request and every error of the update handler.
+func WithErrorHandler(f func(r *http.Request, err error)) Option {
+	return func(h *Handler) {
+		h.onError = f
+	}
+}
+
+func NewHandler(handler updates.Handler, opts ...Option) *Handler {
+	h := &Handler{
+		handler:     handler,
+		maxBodySize: defaultMaxBodySize,
+		onError:     func(*http.Request, error) {},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.checkSource(r) {
+		h.counters.rejectedSource.Add(1)
+		h.reject(w, r, http.StatusForbidden, errors.New("webhook: source address "+r.RemoteAddr+" is not allowed"))
+		return
+	}
+	if status, ok := h.checkSecret(r); !ok {
+		h.counters.rejectedSecret.Add(1)
+		h.reject(w, r, status, errors.New("webhook: invalid secret token"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.counters.rejectedRequest.Add(1)
+		w.Header().Set("Allow", http.MethodPost)
+		h.reject(w, r, http.StatusMethodNotAllowed, errors.New("webhook: method "+r.Method+" is not allowed"))
+		return
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		h.counters.rejectedRequest.Add(1)
+		h.reject(w, r, http.StatusUnsupportedMediaType, errors.New("webhook: content type is not application/json"))
+		return
+	}
+
+	body := payload{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodySize)).Decode(&body)
+	if err != nil {
+		h.counters.rejectedRequest.Add(1)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.reject(w, r, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		h.reject(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, update := range body.Updates {
+		if err := h.handler.HandleUpdate(r.Context(), update); err != nil {
+			h.counters.handlerErrors.Add(1)
+			h.reject(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	h.counters.accepted.Add(1)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	h.onError(r, err)
+	http.Error(w, http.StatusText(status), status)
+}
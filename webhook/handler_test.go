@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const body = `{"updates":[{"update_id":1,"text":"hello"}]}`
+
+func TestHandlerServeHTTP(t *testing.T) {
+	allowed := WithAllowedNetworks(netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8::/32"))
+	proxies := WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"))
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		method     string
+		path       string
+		remote     string
+		header     map[string]string
+		forwarded  []string
+		body       string
+		failing    bool
+		wantStatus int
+		wantStats  Stats
+	}{
+		{name: "no checks", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "secret path", opts: []Option{WithSecretPath("s3cret")}, path: "/yama/s3cret", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "secret path with slash", opts: []Option{WithSecretPath("s3cret")}, path: "/yama/s3cret/", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "wrong secret path", opts: []Option{WithSecretPath("s3cret")}, path: "/yama/guess", wantStatus: http.StatusNotFound, wantStats: Stats{RejectedSecret: 1}},
+		{name: "secret only in parent path", opts: []Option{WithSecretPath("s3cret")}, path: "/s3cret/yama", wantStatus: http.StatusNotFound, wantStats: Stats{RejectedSecret: 1}},
+		{name: "secret header", opts: []Option{WithSecretHeader("X-Secret", "s3cret")}, header: map[string]string{"X-Secret": "s3cret"}, wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "wrong secret header", opts: []Option{WithSecretHeader("X-Secret", "s3cret")}, header: map[string]string{"X-Secret": "s3cre"}, wantStatus: http.StatusUnauthorized, wantStats: Stats{RejectedSecret: 1}},
+		{name: "missing secret header", opts: []Option{WithSecretHeader("X-Secret", "s3cret")}, wantStatus: http.StatusUnauthorized, wantStats: Stats{RejectedSecret: 1}},
+		{name: "allowed network", opts: []Option{allowed}, remote: "203.0.113.7:4000", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "allowed IPv6 network", opts: []Option{allowed}, remote: "[2001:db8::1]:4000", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "IPv4-mapped address", opts: []Option{allowed}, remote: "[::ffff:203.0.113.7]:4000", wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1}},
+		{name: "not allowed network", opts: []Option{allowed}, remote: "198.51.100.1:4000", wantStatus: http.StatusForbidden, wantStats: Stats{RejectedSource: 1}},
+		{name: "source is checked before secret", opts: []Option{allowed, WithSecretPath("s3cret")}, remote: "198.51.100.1:4000", path: "/s3cret", wantStatus: http.StatusForbidden, wantStats: Stats{RejectedSource: 1}},
+		{
+			name: "forwarded by trusted proxy", opts: []Option{allowed, proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"203.0.113.7"},
+			wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1},
+		},
+		{
+			name: "rightmost untrusted hop", opts: []Option{allowed, proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"198.51.100.1, 203.0.113.7, 10.0.0.3"},
+			wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1},
+		},
+		{
+			name: "spoofed hop before the client", opts: []Option{allowed, proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"203.0.113.7, 198.51.100.1"},
+			wantStatus: http.StatusForbidden, wantStats: Stats{RejectedSource: 1},
+		},
+		{
+			name: "hops in several headers", opts: []Option{allowed, proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"198.51.100.1", "203.0.113.7"},
+			wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1},
+		},
+		{
+			name: "forwarded header from untrusted client", opts: []Option{allowed, proxies},
+			remote: "198.51.100.1:4000", forwarded: []string{"203.0.113.7"},
+			wantStatus: http.StatusForbidden, wantStats: Stats{RejectedSource: 1},
+		},
+		{
+			name: "malformed hop", opts: []Option{allowed, proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"203.0.113.7, garbage"},
+			wantStatus: http.StatusForbidden, wantStats: Stats{RejectedSource: 1},
+		},
+		{
+			name: "only trusted proxies", opts: []Option{WithAllowedNetworks(netip.MustParsePrefix("10.0.0.0/8")), proxies},
+			remote: "10.0.0.2:4000", forwarded: []string{"10.0.0.3"},
+			wantStatus: http.StatusOK, wantStats: Stats{Accepted: 1},
+		},
+		{name: "wrong method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed, wantStats: Stats{RejectedRequest: 1}},
+		{name: "wrong content type", header: map[string]string{"Content-Type": "text/plain"}, wantStatus: http.StatusUnsupportedMediaType, wantStats: Stats{RejectedRequest: 1}},
+		{name: "malformed body", body: "{", wantStatus: http.StatusBadRequest, wantStats: Stats{RejectedRequest: 1}},
+		{name: "body too large", opts: []Option{WithMaxBodySize(10)}, wantStatus: http.StatusRequestEntityTooLarge, wantStats: Stats{RejectedRequest: 1}},
+		{name: "handler error", failing: true, wantStatus: http.StatusInternalServerError, wantStats: Stats{HandlerErrors: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []types.Update
+			h := NewHandler(updates.HandlerFunc(func(_ context.Context, u types.Update) error {
+				if tt.failing {
+					return errors.New("failed")
+				}
+				handled = append(handled, u)
+				return nil
+			}), tt.opts...)
+
+			method, path, b := http.MethodPost, "/webhook", body
+			if tt.method != "" {
+				method = tt.method
+			}
+			if tt.path != "" {
+				path = tt.path
+			}
+			if tt.body != "" {
+				b = tt.body
+			}
+			r := httptest.NewRequest(method, path, strings.NewReader(b))
+			r.Header.Set("Content-Type", "application/json; charset=utf-8")
+			if tt.remote != "" {
+				r.RemoteAddr = tt.remote
+			}
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := h.Stats(); got != tt.wantStats {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.wantStats)
+			}
+			if wantHandled := tt.wantStatus == http.StatusOK; wantHandled != (len(handled) == 1) {
+				t.Errorf("handled %d updates", len(handled))
+			}
+		})
+	}
+}
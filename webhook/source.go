@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/Liriker/YaMa/types"
+	"github.com/Liriker/YaMa/updates"
+	"sync"
+)
+
+var errNotReceiving = errors.New("webhook: updates are not being received")
+
+// Source - webhook Handler that is also an updates.Source.
+// Mount it on a mux and read the updates from Updates. A request is answered with 200 only after its updates
+// are taken by the consumer, and with 500 while nobody reads them, so the Bot API delivers them again.
+type Source struct {
+	*Handler
+	mu   sync.RWMutex
+	ch   chan types.Update
+	done <-chan struct{}
+}
+
+// NewSource - creates Source with the options of Handler.
+func NewSource(opts ...Option) *Source {
+	s := &Source{}
+	s.Handler = NewHandler(updates.HandlerFunc(s.push), opts...)
+	return s
+}
+
+func (s *Source) Updates(ctx context.Context) <-chan types.Update {
+	ch := make(chan types.Update)
+	s.mu.Lock()
+	s.ch = ch
+	s.done = ctx.Done()
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.ch == ch {
+			s.ch = nil
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// Err - always nil, the webhook source is stopped only by ctx.
+func (s *Source) Err() error {
+	return nil
+}
+
+func (s *Source) push(ctx context.Context, update types.Update) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ch == nil {
+		return errNotReceiving
+	}
+	select {
+	case s.ch <- update:
+		return nil
+	case <-s.done:
+		return errNotReceiving
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
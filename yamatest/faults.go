@@ -0,0 +1,263 @@
+package yamatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault - failure injected into responses of the fake server.
+// Endpoint - method path relative to the base URL, e.g. "messages/sendText/". Empty matches every method.
+// Nth - number of the first matching call the fault applies to, counted from when the fault was added. 0 means 1.
+// Times - number of consecutive calls the fault applies to. 0 means all calls from Nth on.
+// Latency - delay before the response.
+// Reset - close the connection without a response.
+// Status - HTTP status of the response. If it is 0 and Description is set, the status is 200.
+// RetryAfter - value of the Retry-After header.
+// Description, Code - description and code of an ok=false response.
+// Body - raw response body, e.g. malformed JSON. It is used instead of the ok=false envelope.
+type Fault struct {
+	Endpoint    string
+	Nth         int
+	Times       int
+	Latency     time.Duration
+	Reset       bool
+	Status      int
+	RetryAfter  time.Duration
+	Description string
+	Code        string
+	Body        string
+}
+
+// Scenario - set of faults, e.g. loaded from a JSON file:
+//
+//	{"faults": [
+//		{"endpoint": "messages/sendText/", "status": 429, "retry_after": "1s", "times": 2},
+//		{"endpoint": "messages/getUpdates/", "latency": "200ms"},
+//		{"endpoint": "chats/create/", "nth": 3, "times": 1, "reset": true}
+//	]}
+type Scenario struct {
+	Faults []Fault `json:"faults"`
+}
+
+// LoadScenario - reads Scenario from the JSON file.
+func LoadScenario(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario - decodes Scenario from JSON.
+func ParseScenario(data []byte) (Scenario, error) {
+	s := Scenario{}
+	err := json.Unmarshal(data, &s)
+	return s, err
+}
+
+// Latency - every call to endpoint is delayed by d.
+func Latency(endpoint string, d time.Duration) Fault {
+	return Fault{Endpoint: endpoint, Latency: d}
+}
+
+// RateLimited - the next times calls to endpoint fail with 429 and Retry-After.
+func RateLimited(endpoint string, times int, retryAfter time.Duration) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: http.StatusTooManyRequests, RetryAfter: retryAfter, Description: "too_many_requests"}
+}
+
+// ServerErrors - burst of times responses with the 5xx status.
+func ServerErrors(endpoint string, times, status int) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: status, Body: http.StatusText(status)}
+}
+
+// MalformedJSON - the next times successful calls to endpoint return a body that is not valid JSON.
+func MalformedJSON(endpoint string, times int) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: http.StatusOK, Body: `{"ok": true, "message_id":`}
+}
+
+// NotOk - the next times calls to endpoint return ok=false with description.
+func NotOk(endpoint string, times int, status int, description string) Fault {
+	return Fault{Endpoint: endpoint, Times: times, Status: status, Description: description}
+}
+
+// ResetOnCall - the nth call to endpoint is answered by closing the connection.
+func ResetOnCall(endpoint string, nth int) Fault {
+	return Fault{Endpoint: endpoint, Nth: nth, Times: 1, Reset: true}
+}
+
+type faultJSON struct {
+	Endpoint    string `json:"endpoint"`
+	Nth         int    `json:"nth"`
+	Times       int    `json:"times"`
+	Latency     string `json:"latency"`
+	Reset       bool   `json:"reset"`
+	Status      int    `json:"status"`
+	RetryAfter  string `json:"retry_after"`
+	Description string `json:"description"`
+	Code        string `json:"code"`
+	Body        string `json:"body"`
+}
+
+// UnmarshalJSON - decodes Fault with durations written as strings like "250ms".
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	v := faultJSON{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = Fault{
+		Endpoint:    v.Endpoint,
+		Nth:         v.Nth,
+		Times:       v.Times,
+		Reset:       v.Reset,
+		Status:      v.Status,
+		Description: v.Description,
+		Code:        v.Code,
+		Body:        v.Body,
+	}
+	var err error
+	if f.Latency, err = parseDuration(v.Latency); err != nil {
+		return err
+	}
+	f.RetryAfter, err = parseDuration(v.RetryAfter)
+	return err
+}
+
+// MarshalJSON - encodes Fault in the format of UnmarshalJSON.
+func (f Fault) MarshalJSON() ([]byte, error) {
+	v := faultJSON{
+		Endpoint:    f.Endpoint,
+		Nth:         f.Nth,
+		Times:       f.Times,
+		Reset:       f.Reset,
+		Status:      f.Status,
+		Description: f.Description,
+		Code:        f.Code,
+		Body:        f.Body,
+	}
+	if f.Latency > 0 {
+		v.Latency = f.Latency.String()
+	}
+	if f.RetryAfter > 0 {
+		v.RetryAfter = f.RetryAfter.String()
+	}
+	return json.Marshal(v)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+type activeFault struct {
+	Fault
+	calls int
+}
+
+func (f *activeFault) match(endpoint string) bool {
+	if f.Endpoint != "" && strings.Trim(f.Endpoint, "/") != endpoint {
+		return false
+	}
+	f.calls++
+	first := max(f.Nth, 1)
+	return f.calls >= first && (f.Times == 0 || f.calls < first+f.Times)
+}
+
+func (f *activeFault) respond() bool {
+	return f.Reset || f.Status != 0 || f.Description != "" || f.Body != ""
+}
+
+// AddFault - injects faults into the following calls.
+func (s *Server) AddFault(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range faults {
+		s.faults = append(s.faults, &activeFault{Fault: f})
+	}
+}
+
+// Apply - injects the faults of the scenario.
+func (s *Server) Apply(sc Scenario) {
+	s.AddFault(sc.Faults...)
+}
+
+// ClearFaults - removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Calls - number of calls to endpoint received by the server, including failed ones.
+func (s *Server) Calls(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[strings.Trim(endpoint, "/")]
+}
+
+// injectFault - applies faults matching the request. It returns true if the request has been answered.
+func (s *Server) injectFault(w http.ResponseWriter, r *http.Request) bool {
+	endpoint := strings.Trim(r.URL.Path, "/")
+	s.mu.Lock()
+	s.calls[endpoint]++
+	var latency time.Duration
+	var fault *Fault
+	for _, f := range s.faults {
+		if !f.match(endpoint) {
+			continue
+		}
+		latency += f.Latency
+		if fault == nil && f.respond() {
+			fault = &f.Fault
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if fault == nil {
+		return false
+	}
+
+	if fault.Reset {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Round(time.Second)/time.Second)))
+	}
+	status := fault.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if fault.Body != "" {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(fault.Body))
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	resp := map[string]any{"ok": false, "description": fault.Description}
+	if fault.Code != "" {
+		resp["code"] = fault.Code
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+	return true
+}
@@ -0,0 +1,299 @@
+package yamatest
+
+import (
+	"encoding/json"
+	"github.com/Liriker/YaMa/types"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxMemory = 32 << 20
+
+type updateRequest struct {
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+func (s *Server) sendText(w http.ResponseWriter, r *http.Request) {
+	m := types.NewMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+	if m.ChatID == "" && m.Login == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request: chat_id or login is required")
+		return
+	}
+	if m.Text == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request: text is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.payloads[m.PayloadID]; ok && m.PayloadID != "" {
+		writeJSON(w, map[string]any{"ok": true, "message_id": id})
+		return
+	}
+	id := s.store(SentMessage{
+		Method:         "sendText",
+		ChatID:         m.ChatID,
+		Login:          m.Login,
+		Text:           m.Text,
+		PayloadID:      m.PayloadID,
+		ReplyMessageID: m.ReplyMessageID,
+		ThreadID:       m.ThreadID,
+		InlineKeyboard: m.InlineKeyboard,
+	})
+	if m.PayloadID != "" {
+		s.payloads[m.PayloadID] = id
+	}
+	writeJSON(w, map[string]any{"ok": true, "message_id": id})
+}
+
+func (s *Server) sendFiles(method string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxMemory); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+			return
+		}
+		m := SentMessage{
+			Method:   method,
+			ChatID:   r.FormValue("chat_id"),
+			Login:    r.FormValue("login"),
+			ThreadID: r.FormValue("thread_id"),
+		}
+		if m.ThreadID == "0" {
+			m.ThreadID = ""
+		}
+		if m.ChatID == "" && m.Login == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request: chat_id or login is required")
+			return
+		}
+		for _, field := range []string{"document", "image", "images"} {
+			for _, fh := range r.MultipartForm.File[field] {
+				f, err := fh.Open()
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+					return
+				}
+				data, err := io.ReadAll(f)
+				f.Close()
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+					return
+				}
+				m.Files = append(m.Files, File{Name: fh.Filename, Data: data})
+			}
+		}
+		if len(m.Files) == 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request: no file in the request")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := s.store(m)
+		writeJSON(w, map[string]any{"ok": true, "message_id": id})
+	}
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	req := types.NewDeleteMessageRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.sent {
+		m := &s.sent[i]
+		if m.MessageID == req.MessageID && m.ChatID == req.ChatID && m.Login == req.Login && !m.Deleted {
+			m.Deleted = true
+			writeJSON(w, map[string]any{"ok": true, "message_id": m.MessageID})
+			return
+		}
+	}
+	for _, u := range s.updates {
+		if u.MessageID == req.MessageID && !s.deleted[u.MessageID] {
+			s.deleted[u.MessageID] = true
+			writeJSON(w, map[string]any{"ok": true, "message_id": u.MessageID})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "message_not_found")
+}
+
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
+	var id json.Number
+	if err := json.NewDecoder(r.Body).Decode(&id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	f, ok := s.files[id.String()]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "file_not_found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(f.Data)
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request) {
+	req := updateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := []types.Update{}
+	for _, u := range s.updates {
+		if u.UpdateID >= req.Offset && int64(len(batch)) < req.Limit {
+			batch = append(batch, u)
+		}
+	}
+	writeJSON(w, map[string]any{"ok": true, "updates": batch})
+}
+
+func (s *Server) createChat(w http.ResponseWriter, r *http.Request) {
+	req := types.NewChat{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request: name is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chatID++
+	c := &Chat{
+		ID:          "0/0/" + strconv.FormatInt(s.chatID, 10),
+		Name:        req.Name,
+		Description: req.Description,
+		Channel:     req.Channel,
+		Admins:      logins(req.Admins),
+		Members:     logins(req.Members),
+		Subscribers: logins(req.Subscribers),
+	}
+	s.chats[c.ID] = c
+	writeJSON(w, map[string]any{"ok": true, "chat_id": c.ID})
+}
+
+func (s *Server) updateMembers(w http.ResponseWriter, r *http.Request) {
+	req := types.ChatUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[req.ChatID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "chat_not_found")
+		return
+	}
+	c.Members = append(c.Members, logins(req.Members)...)
+	c.Admins = append(c.Admins, logins(req.Admins)...)
+	c.Subscribers = append(c.Subscribers, logins(req.Subscribers)...)
+	for _, u := range req.Remove {
+		c.Members = remove(c.Members, u.Login)
+		c.Admins = remove(c.Admins, u.Login)
+		c.Subscribers = remove(c.Subscribers, u.Login)
+	}
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+func (s *Server) getUserLink(w http.ResponseWriter, r *http.Request) {
+	login := ""
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login == "" {
+		login = r.URL.Query().Get("login")
+	}
+	if login == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request: login is required")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"ok":        true,
+		"id":        login,
+		"chat_link": "https://messenger.360.yandex.ru/#/chats/" + login,
+		"call_link": "https://messenger.360.yandex.ru/#/call/" + login,
+	})
+}
+
+func (s *Server) getSelf(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, s.bot)
+}
+
+func (s *Server) updateSelf(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		WebhookUrl *string `json:"webhook_url"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bot.WebhookUrl = ""
+	if req.WebhookUrl != nil {
+		s.bot.WebhookUrl = *req.WebhookUrl
+	}
+	writeJSON(w, s.bot)
+}
+
+// store - saves the sent message with a new ID. s.mu must be held.
+func (s *Server) store(m SentMessage) int64 {
+	s.messageID++
+	m.MessageID = s.messageID
+	m.Time = time.Now()
+	s.sent = append(s.sent, m)
+	s.cond.Broadcast()
+	return m.MessageID
+}
+
+func logins(users []types.User) []string {
+	var res []string
+	for _, u := range users {
+		res = append(res, u.Login)
+	}
+	return res
+}
+
+func remove(list []string, login string) []string {
+	res := list[:0]
+	for _, l := range list {
+		if l != login {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "description": description})
+}
@@ -0,0 +1,301 @@
+// Package yamatest provides an in-memory fake of Yandex Messenger Bot API for tests of bots built on YaMa.
+package yamatest
+
+import (
+	"fmt"
+	"github.com/Liriker/YaMa/transport"
+	"github.com/Liriker/YaMa/types"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// T - part of testing.TB used by the assertion helpers.
+type T interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// File - file attached to a sent message.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// SentMessage - message sent by the bot through the fake server.
+// Method - name of the API method: "sendText", "sendFile", "sendImage" or "sendGallery".
+// Files - documents and images of file messages, in the order they were sent.
+// Deleted - the message was deleted with messages/delete.
+type SentMessage struct {
+	MessageID      int64
+	Method         string
+	ChatID         string
+	Login          string
+	Text           string
+	PayloadID      string
+	ReplyMessageID string
+	ThreadID       string
+	InlineKeyboard []types.Button
+	Files          []File
+	Deleted        bool
+	Time           time.Time
+}
+
+// Chat - chat created by the bot with chats/create and changed with chats/updateMembers.
+type Chat struct {
+	ID          string
+	Name        string
+	Description string
+	Channel     bool
+	Admins      []string
+	Members     []string
+	Subscribers []string
+}
+
+// Server - fake Bot API served by httptest.Server.
+// Failures can be scripted with AddFault and Apply.
+// All methods are safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+	token  string
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	messageID int64
+	updateID  int64
+	chatID    int64
+	sent      []SentMessage
+	payloads  map[string]int64
+	updates   []types.Update
+	deleted   map[int64]bool
+	files     map[string]File
+	chats     map[string]*Chat
+	bot       types.BotInfo
+	faults    []*activeFault
+	calls     map[string]int
+}
+
+// NewServer - starts the fake server. Requests must be authorized with token.
+// Close the server when the test is over.
+func NewServer(token string) *Server {
+	s := &Server{
+		token:    token,
+		payloads: map[string]int64{},
+		files:    map[string]File{},
+		chats:    map[string]*Chat{},
+		calls:    map[string]int{},
+		deleted:  map[int64]bool{},
+		bot: types.BotInfo{
+			ID:            "bot",
+			DisplayName:   "Test bot",
+			Login:         "bot@yamatest",
+			Organizations: []int64{1},
+		},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.server = httptest.NewServer(s.handler())
+	return s
+}
+
+// URL - base URL of the fake API, to be used with transport.WithBaseURL.
+func (s *Server) URL() string {
+	return s.server.URL + "/"
+}
+
+// Close - shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// NewClient - client of the fake server authorized with its token.
+func (s *Server) NewClient(opts ...transport.Option) *transport.Client {
+	opts = append([]transport.Option{transport.WithBaseURL(s.URL())}, opts...)
+	return transport.NewClient(s.token, opts...)
+}
+
+// InjectUpdate - adds an incoming update that will be returned by getUpdates.
+// Zero UpdateID, MessageID and Timestamp are filled in. The stored update is returned.
+func (s *Server) InjectUpdate(u types.Update) types.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateID++
+	if u.UpdateID == 0 {
+		u.UpdateID = s.updateID
+	} else {
+		s.updateID = max(s.updateID, u.UpdateID)
+	}
+	if u.MessageID == 0 {
+		s.messageID++
+		u.MessageID = s.messageID
+	}
+	if u.Timestamp == 0 {
+		u.Timestamp = time.Now().Unix()
+	}
+	s.updates = append(s.updates, u)
+	return u
+}
+
+// InjectText - adds an incoming text message from login in a private chat.
+func (s *Server) InjectText(login, text string) types.Update {
+	return s.InjectUpdate(types.Update{
+		From: types.Sender{Login: login},
+		Chat: types.Chat{Type: types.PrivateChatType},
+		Text: text,
+	})
+}
+
+// InjectGroupText - adds an incoming text message from login in the group chat chatID.
+func (s *Server) InjectGroupText(chatID, login, text string) types.Update {
+	return s.InjectUpdate(types.Update{
+		From: types.Sender{Login: login},
+		Chat: types.Chat{Type: types.GroupChatType, ID: chatID},
+		Text: text,
+	})
+}
+
+// InjectCallback - adds a click of login on a button with callback data in a private chat.
+func (s *Server) InjectCallback(login string, data interface{}) types.Update {
+	return s.InjectUpdate(types.Update{
+		From:         types.Sender{Login: login},
+		Chat:         types.Chat{Type: types.PrivateChatType},
+		CallbackData: data,
+	})
+}
+
+// AddFile - makes data available for getFile with id, e.g. File.ID of an injected update.
+func (s *Server) AddFile(id string, name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[id] = File{Name: name, Data: data}
+}
+
+// SetBotInfo - information about the bot returned by self/get/ and self/update/.
+func (s *Server) SetBotInfo(info types.BotInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bot = info
+}
+
+// Webhook - webhook URL set by the bot, empty if there is none.
+func (s *Server) Webhook() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bot.WebhookUrl
+}
+
+// Sent - messages sent by the bot, in the order they were sent.
+func (s *Server) Sent() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := make([]SentMessage, len(s.sent))
+	copy(sent, s.sent)
+	return sent
+}
+
+// SentTo - messages sent to the chat or the private chat with login.
+func (s *Server) SentTo(chatOrLogin string) []SentMessage {
+	var sent []SentMessage
+	for _, m := range s.Sent() {
+		if m.ChatID == chatOrLogin || m.Login == chatOrLogin {
+			sent = append(sent, m)
+		}
+	}
+	return sent
+}
+
+// WaitSent - blocks until at least n messages are sent or timeout expires, and returns the sent messages.
+func (s *Server) WaitSent(n int, timeout time.Duration) []SentMessage {
+	timer := time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+	s.mu.Lock()
+	for len(s.sent) < n && time.Now().Before(deadline) {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+	return s.Sent()
+}
+
+// Deleted - reports whether the bot deleted the message of an injected update.
+func (s *Server) Deleted(messageID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleted[messageID]
+}
+
+// Chat - chat created by the bot, nil if there is no chat with id.
+func (s *Server) Chat(id string) *Chat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[id]
+	if !ok {
+		return nil
+	}
+	cp := *c
+	cp.Admins = slices.Clone(c.Admins)
+	cp.Members = slices.Clone(c.Members)
+	cp.Subscribers = slices.Clone(c.Subscribers)
+	return &cp
+}
+
+// AssertSent - reports an error unless a message with text was sent to the chat or the private chat with login.
+func (s *Server) AssertSent(t T, chatOrLogin, text string) {
+	t.Helper()
+	var texts []string
+	for _, m := range s.SentTo(chatOrLogin) {
+		if m.Text == text && !m.Deleted {
+			return
+		}
+		texts = append(texts, fmt.Sprintf("%q", m.Text))
+	}
+	t.Errorf("yamatest: no message %q sent to %s, sent: [%s]", text, chatOrLogin, strings.Join(texts, ", "))
+}
+
+// AssertSentCount - reports an error unless exactly n messages were sent.
+func (s *Server) AssertSentCount(t T, n int) {
+	t.Helper()
+	if got := len(s.Sent()); got != n {
+		t.Errorf("yamatest: %d messages sent, want %d", got, n)
+	}
+}
+
+// AssertNothingSent - reports an error if any message was sent.
+func (s *Server) AssertNothingSent(t T) {
+	t.Helper()
+	s.AssertSentCount(t, 0)
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /messages/sendText/", s.sendText)
+	mux.HandleFunc("POST /messages/sendFile/", s.sendFiles("sendFile"))
+	mux.HandleFunc("POST /messages/sendImage/", s.sendFiles("sendImage"))
+	mux.HandleFunc("POST /messages/sendGallery/", s.sendFiles("sendGallery"))
+	mux.HandleFunc("POST /messages/delete", s.deleteMessage)
+	mux.HandleFunc("GET /messages/getFile/", s.getFile)
+	mux.HandleFunc("POST /messages/getUpdates/", s.getUpdates)
+	mux.HandleFunc("POST /chats/create/", s.createChat)
+	mux.HandleFunc("POST /chats/updateMembers/", s.updateMembers)
+	mux.HandleFunc("GET /users/getUserLink/", s.getUserLink)
+	mux.HandleFunc("GET /self/get/", s.getSelf)
+	mux.HandleFunc("POST /self/update/", s.updateSelf)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.injectFault(w, r) {
+			return
+		}
+		if r.Header.Get("Authorization") != "OAuth "+s.token {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}